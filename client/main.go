@@ -5,15 +5,25 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"log"
 	"mime"
 	"os"
 	"path/filepath"
+	"time"
 
 	uploadpb "github.com/benjamin-rood/x-grpc/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 const (
@@ -24,11 +34,29 @@ const (
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	transformPath := flag.String("transform", "", "path to a JSON transform spec, to choose the server's post-processing of the upload")
+	printJSON := flag.Bool("json", false, "print the server's response as JSON on stdout, e.g. for CI jobs to check")
+	conflict := flag.String("conflict", "overwrite", "what the server does if the file name is taken: overwrite, fail, rename or keep-versions")
+	flag.Parse()
+	if flag.NArg() < 1 {
 		log.Fatal("File path argument is missing.")
 	}
 
-	filePath := os.Args[1]
+	filePath := flag.Arg(0)
+
+	conflictPolicy, ok := conflictPolicies[*conflict]
+	if !ok {
+		log.Fatalf("unknown -conflict policy '%s'", *conflict)
+	}
+
+	var transformSpec string
+	if *transformPath != "" {
+		spec, err := os.ReadFile(*transformPath)
+		if err != nil {
+			log.Fatalf("failed to read transform spec: %s", err)
+		}
+		transformSpec = string(spec)
+	}
 
 	// Open the file to be uploaded.
 	file, err := os.Open(filePath)
@@ -42,7 +70,8 @@ func main() {
 	fileExt := filepath.Ext(fileName)
 	mimeType := mime.TypeByExtension(fileExt)
 	if mimeType == "" {
-		log.Fatalln("can't detect mime-type of file", mimeType)
+		// the server can work it out for itself
+		log.Println("can't detect mime-type of file from extension", fileExt)
 	}
 
 	// Set up a connection to the server (using insecure because this is not real)
@@ -55,13 +84,121 @@ func main() {
 	// Create a client instance.
 	client := uploadpb.NewUploaderClient(conn)
 
+	// derive an upload ID from the file, so re-running the command after a
+	// failure picks up where the last attempt left off
+	uploadID, err := resumableUploadID(file)
+	if err != nil {
+		log.Fatalf("failed to stat file: %s", err)
+	}
+
+	var resp *uploadpb.UploadResponse
+	for attempt := 1; ; attempt++ {
+		resp, err = upload(client, file, fileName, mimeType, transformSpec, conflictPolicy, uploadID)
+		if err == nil {
+			break
+		}
+		if attempt == maxAttempts || !retryable(err) {
+			log.Fatalf("failed: %s", err)
+		}
+		log.Printf("upload interrupted (%s), resuming...", err)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	log.Printf("uploaded file: %v (%v bytes, sha256 %x, detected as %s, stored as %s in %s)", resp.FileName, resp.Size, resp.Sha256,
+		resp.DetectedMimeType, resp.StoredPath, time.Duration(resp.UploadMicros)*time.Microsecond)
+	if resp.Version != "" {
+		log.Printf("stored as version %s", resp.Version)
+	}
+	if resp.KeptFileName != "" {
+		log.Printf("kept the file it replaced as %s", resp.KeptFileName)
+	}
+	if resp.JobId != "" {
+		// the server is post-processing the upload in the background, wait and see how it goes
+		job, err := waitForJob(client, resp.JobId)
+		if err != nil {
+			log.Fatalf("failed to get job status: %s", err)
+		}
+		if job.State == uploadpb.JobState_JOB_STATE_FAILED {
+			log.Fatalf("post-processing failed after %d attempts: %s", job.Attempts, job.Error)
+		}
+		// as if the server had done it all before responding
+		resp.FailedLines, resp.LineErrors, resp.Processing = job.FailedLines, job.LineErrors, job.Processing
+	}
+	if p := resp.Processing; p.GetProcessed() {
+		log.Printf("wrote %s in %s: %d keys removed, %d integers rewritten", p.ModifiedFileName,
+			time.Duration(p.ProcessingMicros)*time.Microsecond, p.KeysRemoved, p.IntsRewritten)
+		if p.IntsSaturated > 0 {
+			log.Printf("%d integers were out of range, and saturated", p.IntsSaturated)
+		}
+	}
+	if resp.FailedLines > 0 {
+		log.Printf("%d lines could not be processed", resp.FailedLines)
+		for _, le := range resp.LineErrors {
+			log.Printf("  line %d: %s", le.Line, le.Error)
+		}
+	}
+	if *printJSON {
+		out, err := protojson.Marshal(resp)
+		if err != nil {
+			log.Fatalf("failed to marshal response: %s", err)
+		}
+		fmt.Println(string(out))
+	}
+}
+
+// polls a post-processing job until it has succeeded or failed
+func waitForJob(client uploadpb.UploaderClient, jobID string) (*uploadpb.JobStatusResponse, error) {
+	log.Printf("waiting for post-processing job %s", jobID)
+	for {
+		job, err := client.GetJobStatus(context.Background(), &uploadpb.JobStatusRequest{JobId: jobID})
+		if err != nil {
+			return nil, err
+		}
+		switch job.State {
+		case uploadpb.JobState_JOB_STATE_SUCCEEDED, uploadpb.JobState_JOB_STATE_FAILED:
+			return job, nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// -conflict flag values
+var conflictPolicies = map[string]uploadpb.ConflictPolicy{
+	"overwrite":     uploadpb.ConflictPolicy_CONFLICT_POLICY_OVERWRITE,
+	"fail":          uploadpb.ConflictPolicy_CONFLICT_POLICY_FAIL,
+	"rename":        uploadpb.ConflictPolicy_CONFLICT_POLICY_RENAME,
+	"keep-versions": uploadpb.ConflictPolicy_CONFLICT_POLICY_KEEP_VERSIONS,
+}
+
+// give up resuming after this many attempts
+const maxAttempts = 5
+
+// uploads the file from wherever the server got up to in a previous attempt
+func upload(client uploadpb.UploaderClient, file *os.File, fileName, mimeType, transformSpec string, conflictPolicy uploadpb.ConflictPolicy, uploadID string) (*uploadpb.UploadResponse, error) {
+	// ask the server how much of the file it already has
+	offsetResp, err := client.QueryUploadOffset(context.Background(), &uploadpb.UploadOffsetRequest{UploadId: uploadID})
+	if err != nil {
+		return nil, err
+	}
+	offset := offsetResp.GetOffset()
+	if offset > 0 {
+		log.Printf("resuming upload %s from byte %d", uploadID, offset)
+	}
+	// digest of the whole file, including anything the server already has
+	digest := sha256.New()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Fatalf("failed to seek file: %s", err)
+	}
+	if _, err := io.CopyN(digest, file, int64(offset)); err != nil {
+		log.Fatalf("failed to read file: %s", err)
+	}
+
 	// Create a stream for uploading the file.
 	stream, err := client.UploadFile(context.Background())
 	if err != nil {
-		log.Fatalf("failed to open stream: %v", err)
+		return nil, err
 	}
 
-	for {
+	for first := true; ; first = false {
 		// Read the file in chunks and send them to the server.
 		buf := make([]byte, chunkSize)
 		n, err := file.Read(buf)
@@ -71,27 +208,79 @@ func main() {
 		if err != nil && err != io.EOF {
 			log.Fatalf("failed to read file: %v", err)
 		}
-		if n == 0 {
+		// always send the first message, even if there's nothing left to send,
+		// so the server knows which upload to complete
+		if n == 0 && !first {
 			break
 		}
 		chunk := buf[:n]
-		if err := stream.Send(&uploadpb.UploadRequest{
+		digest.Write(chunk)
+		crc := crc32.Checksum(chunk, castagnoli)
+		req := &uploadpb.UploadRequest{
 			FileName: fileName,
 			Chunk:    chunk,
 			MimeType: mimeType,
-		}); err != nil {
-			log.Fatalf("%s: failed to send chunk:\n<%s>", err, chunk)
+			UploadId: uploadID,
+			Crc32C:   &crc,
+		}
+		if first {
+			req.Offset = offset
+			req.TransformSpec = transformSpec
+			req.ConflictPolicy = conflictPolicy
+		}
+		if err := stream.Send(req); err != nil {
+			// server has given up on the stream, find out why
+			break
 		}
 
 		// // Simulate connection issues by randomly sleeping between bursts.
 		// sleepTime := rand.Intn(450) + 50 // Sleep for 50-500ms.
 		// time.Sleep(time.Duration(sleepTime) * time.Millisecond)
 	}
+	// finally, let the server check it has the whole file exactly as read from disk
+	// (if the server has already given up on the stream, CloseAndRecv will say why)
+	sum := digest.Sum(nil)
+	_ = stream.Send(&uploadpb.UploadRequest{
+		FileName: fileName,
+		MimeType: mimeType,
+		UploadId: uploadID,
+		Sha256:   sum,
+	})
 
 	// Close the stream and wait for the server to respond.
 	resp, err := stream.CloseAndRecv()
 	if err != nil {
-		log.Fatalf("failed: %s", err)
+		return nil, err
+	}
+	if !bytes.Equal(resp.GetSha256(), sum) {
+		log.Fatalf("server stored sha256 %x, expected %x", resp.GetSha256(), sum)
+	}
+	return resp, nil
+}
+
+// table for the per-chunk CRC-32C checksums
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// upload IDs are a hash of the file's name, size & modification time,
+// so a changed file is never resumed on top of an older partial upload
+func resumableUploadID(file *os.File) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(file.Name())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d", abs, info.Size(), info.ModTime().UnixNano())))
+	return hex.EncodeToString(sum[:16]), nil
+}
+
+// errors worth resuming the upload after
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded:
+		return true
 	}
-	log.Printf("uploaded file: %v (%v bytes)", resp.FileName, resp.Size)
+	return false
 }
@@ -1,17 +1,26 @@
 module github.com/benjamin-rood/x-grpc
 
-go 1.20
+// the disk writers resolve every file name beneath received_files through an os.Root
+// (openat-style, so not even a symlink leads outside of it), which is Go 1.24, and the
+// Root methods they store files with (MkdirAll, Rename, Link...) are Go 1.25
+go 1.25
 
 require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/fxamacker/cbor/v2 v2.5.0
 	github.com/go-test/deep v1.1.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	golang.org/x/text v0.8.0
+	google.golang.org/genproto v0.0.0-20230110181048-76db0878b65f
 	google.golang.org/grpc v1.54.0
 	google.golang.org/protobuf v1.30.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/net v0.8.0 // indirect
 	golang.org/x/sys v0.6.0 // indirect
-	golang.org/x/text v0.8.0 // indirect
-	google.golang.org/genproto v0.0.0-20230110181048-76db0878b65f // indirect
 )
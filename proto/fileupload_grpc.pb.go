@@ -19,7 +19,11 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Uploader_UploadFile_FullMethodName = "/fileupload.Uploader/UploadFile"
+	Uploader_UploadFile_FullMethodName        = "/fileupload.Uploader/UploadFile"
+	Uploader_DownloadFile_FullMethodName      = "/fileupload.Uploader/DownloadFile"
+	Uploader_QueryUploadOffset_FullMethodName = "/fileupload.Uploader/QueryUploadOffset"
+	Uploader_GetJobStatus_FullMethodName      = "/fileupload.Uploader/GetJobStatus"
+	Uploader_ListVersions_FullMethodName      = "/fileupload.Uploader/ListVersions"
 )
 
 // UploaderClient is the client API for Uploader service.
@@ -27,6 +31,10 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type UploaderClient interface {
 	UploadFile(ctx context.Context, opts ...grpc.CallOption) (Uploader_UploadFileClient, error)
+	DownloadFile(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (Uploader_DownloadFileClient, error)
+	QueryUploadOffset(ctx context.Context, in *UploadOffsetRequest, opts ...grpc.CallOption) (*UploadOffsetResponse, error)
+	GetJobStatus(ctx context.Context, in *JobStatusRequest, opts ...grpc.CallOption) (*JobStatusResponse, error)
+	ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error)
 }
 
 type uploaderClient struct {
@@ -71,11 +79,74 @@ func (x *uploaderUploadFileClient) CloseAndRecv() (*UploadResponse, error) {
 	return m, nil
 }
 
+func (c *uploaderClient) DownloadFile(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (Uploader_DownloadFileClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Uploader_ServiceDesc.Streams[1], Uploader_DownloadFile_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &uploaderDownloadFileClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Uploader_DownloadFileClient interface {
+	Recv() (*DownloadResponse, error)
+	grpc.ClientStream
+}
+
+type uploaderDownloadFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *uploaderDownloadFileClient) Recv() (*DownloadResponse, error) {
+	m := new(DownloadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *uploaderClient) QueryUploadOffset(ctx context.Context, in *UploadOffsetRequest, opts ...grpc.CallOption) (*UploadOffsetResponse, error) {
+	out := new(UploadOffsetResponse)
+	err := c.cc.Invoke(ctx, Uploader_QueryUploadOffset_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uploaderClient) GetJobStatus(ctx context.Context, in *JobStatusRequest, opts ...grpc.CallOption) (*JobStatusResponse, error) {
+	out := new(JobStatusResponse)
+	err := c.cc.Invoke(ctx, Uploader_GetJobStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uploaderClient) ListVersions(ctx context.Context, in *ListVersionsRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error) {
+	out := new(ListVersionsResponse)
+	err := c.cc.Invoke(ctx, Uploader_ListVersions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UploaderServer is the server API for Uploader service.
 // All implementations must embed UnimplementedUploaderServer
 // for forward compatibility
 type UploaderServer interface {
 	UploadFile(Uploader_UploadFileServer) error
+	DownloadFile(*DownloadRequest, Uploader_DownloadFileServer) error
+	QueryUploadOffset(context.Context, *UploadOffsetRequest) (*UploadOffsetResponse, error)
+	GetJobStatus(context.Context, *JobStatusRequest) (*JobStatusResponse, error)
+	ListVersions(context.Context, *ListVersionsRequest) (*ListVersionsResponse, error)
 	mustEmbedUnimplementedUploaderServer()
 }
 
@@ -86,6 +157,18 @@ type UnimplementedUploaderServer struct {
 func (UnimplementedUploaderServer) UploadFile(Uploader_UploadFileServer) error {
 	return status.Errorf(codes.Unimplemented, "method UploadFile not implemented")
 }
+func (UnimplementedUploaderServer) DownloadFile(*DownloadRequest, Uploader_DownloadFileServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadFile not implemented")
+}
+func (UnimplementedUploaderServer) QueryUploadOffset(context.Context, *UploadOffsetRequest) (*UploadOffsetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryUploadOffset not implemented")
+}
+func (UnimplementedUploaderServer) GetJobStatus(context.Context, *JobStatusRequest) (*JobStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJobStatus not implemented")
+}
+func (UnimplementedUploaderServer) ListVersions(context.Context, *ListVersionsRequest) (*ListVersionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
 func (UnimplementedUploaderServer) mustEmbedUnimplementedUploaderServer() {}
 
 // UnsafeUploaderServer may be embedded to opt out of forward compatibility for this service.
@@ -125,19 +208,112 @@ func (x *uploaderUploadFileServer) Recv() (*UploadRequest, error) {
 	return m, nil
 }
 
+func _Uploader_DownloadFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UploaderServer).DownloadFile(m, &uploaderDownloadFileServer{stream})
+}
+
+type Uploader_DownloadFileServer interface {
+	Send(*DownloadResponse) error
+	grpc.ServerStream
+}
+
+type uploaderDownloadFileServer struct {
+	grpc.ServerStream
+}
+
+func (x *uploaderDownloadFileServer) Send(m *DownloadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Uploader_QueryUploadOffset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadOffsetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UploaderServer).QueryUploadOffset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Uploader_QueryUploadOffset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UploaderServer).QueryUploadOffset(ctx, req.(*UploadOffsetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Uploader_GetJobStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UploaderServer).GetJobStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Uploader_GetJobStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UploaderServer).GetJobStatus(ctx, req.(*JobStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Uploader_ListVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UploaderServer).ListVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Uploader_ListVersions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UploaderServer).ListVersions(ctx, req.(*ListVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Uploader_ServiceDesc is the grpc.ServiceDesc for Uploader service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
 var Uploader_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "fileupload.Uploader",
 	HandlerType: (*UploaderServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "QueryUploadOffset",
+			Handler:    _Uploader_QueryUploadOffset_Handler,
+		},
+		{
+			MethodName: "GetJobStatus",
+			Handler:    _Uploader_GetJobStatus_Handler,
+		},
+		{
+			MethodName: "ListVersions",
+			Handler:    _Uploader_ListVersions_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "UploadFile",
 			Handler:       _Uploader_UploadFile_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "DownloadFile",
+			Handler:       _Uploader_DownloadFile_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "fileupload.proto",
 }
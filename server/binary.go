@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+/**
+ * CBOR and MessagePack uploads are decoded one top-level value at a time into a tree,
+ * which goes through the rules just like JSON does, then gets written back out in the
+ * same format (or as JSON, if the TransformSpec asks for it).
+ *
+ * Every scalar in the tree keeps the bytes it was decoded from, so anything the rules
+ * leave alone is written back out exactly as it was: integer widths, float precision,
+ * tags, extension types and all. Only what the rules change gets encoded afresh.
+ * Unlike JSON, each top-level value is held in memory in its entirety while it's processed.
+ */
+
+// binaryFormat is a binary structured data format, as far as processing it goes
+type binaryFormat interface {
+	// reads the next top-level value from the upload as a tree, io.EOF once there are no more
+	next() (any, error)
+	// writes a tree back out in the same format
+	encode(w io.Writer, v any) error
+}
+
+// treeMap is a map of keys to values in the order they were decoded;
+// the keys aren't always strings in binary formats
+type treeMap struct {
+	keys, values []any
+}
+
+// treeScalar is any value which isn't a map or an array
+type treeScalar struct {
+	// as it was encoded in the upload, or nil once modified
+	raw []byte
+	// as decoded, e.g. uint64 or float32, or the value from the rule once modified
+	native any
+	// as the rules see it: json.Number, string, bool, nil, or the treeScalar itself
+	// for values with no equivalent in JSON, such as byte strings or timestamps
+	v any
+}
+
+// makes a scalar for a value as decoded
+func newTreeScalar(raw []byte, native any) *treeScalar {
+	s := &treeScalar{raw: raw, native: native}
+	switch n := native.(type) {
+	case string, bool, nil:
+		s.v = n
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, int, uint:
+		s.v = json.Number(fmt.Sprint(n))
+	case big.Int:
+		s.v = json.Number(n.String())
+	case *big.Int:
+		s.v = json.Number(n.String())
+	case float32:
+		s.v = finiteNumber(float64(n), 32)
+	case float64:
+		s.v = finiteNumber(n, 64)
+	default:
+		s.v = s
+	}
+	return s
+}
+
+func finiteNumber(f float64, bitSize int) any {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return f
+	}
+	return floatNumber(f, bitSize)
+}
+
+// applies the rule to a tree, like the jsonTransformer does to a JSON document
+func transformTree(v any, path Path, rule Rule) (any, error) {
+	switch v := v.(type) {
+	case *treeMap:
+		m := &treeMap{}
+		for i, k := range v.keys {
+			segment := fmt.Sprint(k)
+			if ks, ok := k.(*treeScalar); ok {
+				segment = fmt.Sprint(ks.native)
+				if key, ok := ks.v.(string); ok {
+					action, newKey := rule.Key(path, key)
+					if action == DropKey {
+						continue
+					}
+					if action == RenameKey {
+						k = &treeScalar{native: newKey, v: newKey}
+					}
+				}
+			}
+			value, err := transformTree(v.values[i], append(path, segment), rule)
+			if err != nil {
+				return nil, err
+			}
+			m.keys, m.values = append(m.keys, k), append(m.values, value)
+		}
+		return m, nil
+	case []any:
+		for i, elem := range v {
+			var err error
+			if v[i], err = transformTree(elem, append(path, strconv.Itoa(i)), rule); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case *treeScalar:
+		after, err := rule.Value(path, v.v)
+		if err != nil {
+			return nil, ruleFailed(path, err)
+		}
+		if after == v || sameScalar(v.v, after) {
+			return v, nil
+		}
+		native, err := nativeValue(v.native, after)
+		if err != nil {
+			return nil, ruleFailed(path, err)
+		}
+		return &treeScalar{native: native, v: after}, nil
+	}
+	return nil, fmt.Errorf("unexpected %T in tree", v)
+}
+
+// converts a value from a rule into something to encode, keeping
+// integers as integers and floats as floats, where `original` was a number
+func nativeValue(original, v any) (any, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v, nil
+	}
+	switch original.(type) {
+	case float32, float64:
+		return strconv.ParseFloat(n.String(), 64)
+	}
+	if i, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+		return i, nil
+	}
+	if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		return u, nil
+	}
+	if b, ok := new(big.Int).SetString(n.String(), 10); ok {
+		return b, nil
+	}
+	return strconv.ParseFloat(n.String(), 64)
+}
+
+// processes every top-level value of an upload in a binary format,
+// writing each one back out in the same format, or as a line of JSON
+func transformBinary(format binaryFormat, w io.Writer, rule Rule, asJSON bool) error {
+	bw := bufio.NewWriter(w)
+	for {
+		v, err := format.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if v, err = transformTree(v, nil, rule); err != nil {
+			return err
+		}
+		if asJSON {
+			err = writeTreeJSON(bw, v)
+			bw.WriteByte('\n')
+		} else {
+			err = format.encode(bw, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writes a tree out as JSON, keeping the order of keys, with non-string keys as strings
+func writeTreeJSON(w *bufio.Writer, v any) error {
+	switch v := v.(type) {
+	case *treeMap:
+		w.WriteByte('{')
+		for i, k := range v.keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			key := fmt.Sprint(k)
+			if ks, ok := k.(*treeScalar); ok {
+				key = fmt.Sprint(ks.native)
+			}
+			if err := writeJSONValue(w, key); err != nil {
+				return err
+			}
+			w.WriteByte(':')
+			if err := writeTreeJSON(w, v.values[i]); err != nil {
+				return err
+			}
+		}
+		return w.WriteByte('}')
+	case []any:
+		w.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeTreeJSON(w, elem); err != nil {
+				return err
+			}
+		}
+		return w.WriteByte(']')
+	case *treeScalar:
+		if n, ok := v.v.(json.Number); ok {
+			_, err := w.WriteString(n.String())
+			return err
+		}
+		return writeJSONValue(w, jsonable(v.native))
+	}
+	return fmt.Errorf("unexpected %T in tree", v)
+}
+
+func writeJSONValue(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// converts a decoded value into something encoding/json can deal with
+func jsonable(v any) any {
+	switch v := v.(type) {
+	case float32:
+		return jsonable(float64(v))
+	case float64:
+		// same as JavaScript's names for them, e.g. String(Infinity)
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		}
+	case big.Int:
+		return json.Number(v.String())
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, elem := range v {
+			m[fmt.Sprint(k)] = jsonable(elem)
+		}
+		return m
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = jsonable(elem)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = jsonable(elem)
+		}
+	}
+	return v
+}
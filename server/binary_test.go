@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-test/deep"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/grpc"
+)
+
+// encoders for each of the binary formats, along with the processing for them
+var binaryFormats = []struct {
+	name    string
+	marshal func(v any) ([]byte, error)
+	format  func(data []byte) binaryFormat
+}{
+	{"cbor", cborTaggedTimes.Marshal, func(data []byte) binaryFormat {
+		return &cborFormat{dec: cborDecMode.NewDecoder(bytes.NewReader(data))}
+	}},
+	{"msgpack", msgpack.Marshal, func(data []byte) binaryFormat {
+		return &msgpackFormat{dec: msgpack.NewDecoder(bytes.NewReader(data))}
+	}},
+}
+
+// timestamps as tagged values, rather than plain numbers
+var cborTaggedTimes, _ = cbor.EncOptions{Time: cbor.TimeRFC3339, TimeTag: cbor.EncTagRequired}.EncMode()
+
+// values which have no equivalent in JSON, or get lost on the way through it
+type oddities struct {
+	Small     int8              `cbor:"small" msgpack:"small"`
+	Doubled   int32             `cbor:"doubled" msgpack:"doubled"`
+	Big       uint64            `cbor:"big" msgpack:"big"`
+	Single    float32           `cbor:"single" msgpack:"single"`
+	Whole     float64           `cbor:"whole" msgpack:"whole"`
+	NegInf    float64           `cbor:"negInf" msgpack:"negInf"`
+	Bytes     []byte            `cbor:"bytes" msgpack:"bytes"`
+	Time      time.Time         `cbor:"time" msgpack:"time"`
+	NumKeys   map[int]string    `cbor:"numKeys" msgpack:"numKeys"`
+	Nested    []any             `cbor:"nested" msgpack:"nested"`
+	Sorted    map[string]uint16 `cbor:"sorted" msgpack:"sorted"`
+	Undecided any               `cbor:"undecided" msgpack:"undecided"`
+}
+
+var oddValues = oddities{
+	Small:   -3,
+	Doubled: 4,
+	Big:     math.MaxUint64,
+	Single:  1.5,
+	Whole:   4.0, // not an integer, unless integral_floats says so
+	NegInf:  math.Inf(-1),
+	Bytes:   []byte{0xde, 0xad, 0xbe, 0xef},
+	Time:    time.Date(2023, 5, 24, 12, 0, 0, 0, time.UTC),
+	NumKeys: map[int]string{7: "seven"},
+	Nested:  []any{[]any{}, "kiwi", nil, true},
+	Sorted:  map[string]uint16{"b": 1, "a": 3},
+}
+
+func TestTransformBinary_RoundTrip(t *testing.T) {
+	for _, f := range binaryFormats {
+		t.Run(f.name, func(t *testing.T) {
+			data, err := f.marshal(oddValues)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// twice over, to check more than one top-level value works too
+			data = append(data, data...)
+
+			// no rules, nothing changes, down to the last byte
+			var out bytes.Buffer
+			if err := transformBinary(f.format(data), &out, RuleSet{}, false); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), data) {
+				t.Errorf("ROUND TRIP ≠ ORIGINAL\n%x\n≠\n%x", out.Bytes(), data)
+			}
+
+			// the default rules only change what they should, everything else stays the same
+			out.Reset()
+			if err := transformBinary(f.format(data), &out, DefaultRules(), false); err != nil {
+				t.Fatal(err)
+			}
+			want := oddValues
+			want.Doubled *= 1000
+			want.Sorted = map[string]uint16{"b": 1}
+			decode := msgpack.NewDecoder(&out).Decode
+			if f.name == "cbor" {
+				decode = cbor.NewDecoder(&out).Decode
+			}
+			for i := 0; i < 2; i++ {
+				var got oddities
+				if err := decode(&got); err != nil {
+					t.Fatal(err)
+				}
+				if diff := deep.Equal(got, want); diff != nil {
+					t.Errorf("%v", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformBinary(t *testing.T) {
+	// integers as integers, not float64
+	dec := json.NewDecoder(strings.NewReader(jsonBlob))
+	dec.UseNumber()
+	var blob any
+	if err := dec.Decode(&blob); err != nil {
+		t.Fatal(err)
+	}
+	blob = decodedNumbers(blob)
+	var want any
+	if err := json.Unmarshal([]byte(expectedOutput), &want); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range binaryFormats {
+		t.Run(f.name, func(t *testing.T) {
+			data, err := f.marshal(blob)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, asJSON := range []bool{false, true} {
+				var out bytes.Buffer
+				if err := transformBinary(f.format(data), &out, DefaultRules(), asJSON); err != nil {
+					t.Fatal(err)
+				}
+				if !asJSON {
+					// convert to JSON to compare
+					v, err := f.format(out.Bytes()).next()
+					if err != nil {
+						t.Fatal(err)
+					}
+					out.Reset()
+					bw := bufio.NewWriter(&out)
+					if err := writeTreeJSON(bw, v); err != nil {
+						t.Fatal(err)
+					}
+					bw.Flush()
+				}
+				var got any
+				if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+				if diff := deep.Equal(got, want); diff != nil {
+					t.Errorf("%v (as json: %t)", diff, asJSON)
+				}
+			}
+		})
+	}
+}
+
+func TestTransformBinary_Errors(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	cases := []struct {
+		testName string
+		format   binaryFormat
+		err      string
+	}{
+		{"cbor bignum", &cborFormat{dec: cborDecMode.NewDecoder(bytes.NewReader(mustMarshal(t, cbor.Marshal, map[string]any{"n": huge})))}, ""},
+		{"msgpack can't go past 64 bits", &msgpackFormat{dec: msgpack.NewDecoder(bytes.NewReader(mustMarshal(t, msgpack.Marshal, map[string]any{"n": uint64(math.MaxUint64 - 1)})))}, "out of range for a msgpack integer"},
+		{"truncated cbor", &cborFormat{dec: cborDecMode.NewDecoder(strings.NewReader("\xa1\x61n"))}, "not valid cbor data"},
+		{"truncated msgpack", &msgpackFormat{dec: msgpack.NewDecoder(strings.NewReader("\x81\xa1n"))}, "not valid msgpack data"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			var out bytes.Buffer
+			err := transformBinary(tt.format, &out, DefaultRules(), false)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestUploaderService_UploadFile_Binary(t *testing.T) {
+	selfDescribed, _ := cbor.Marshal(cbor.Tag{Number: 55799, Content: map[string]any{"apple": 1, "fig": 2}})
+	packed, _ := msgpack.Marshal(map[string]any{"fig": 2})
+	cases := []struct {
+		testName      string
+		mimeType      string
+		data          []byte
+		transformSpec string
+		want          any // decoded from the modified copy
+	}{
+		{"detected cbor", "", selfDescribed, "", map[any]any{"fig": uint64(2000)}},
+		{"cbor as json", "application/cbor", selfDescribed, `{"rules": [{"rule": "drop-vowel-keys"}], "output": "json"}`, map[string]any{"fig": float64(2)}},
+		{"msgpack", "application/x-msgpack", packed, "", map[string]any{"fig": uint16(2000)}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			buf := NewBufferWriter()
+			uploadSvc := NewCustomUploader(buf, WithMimePolicy(PreferDetected))
+			conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+				uploadpb.RegisterUploaderServer(srv, uploadSvc)
+			})
+			client := uploadpb.NewUploaderClient(conn)
+
+			_, err := sendRequestsToServer(t, client, []*uploadpb.UploadRequest{{
+				FileName:      "upload",
+				MimeType:      tt.mimeType,
+				Chunk:         tt.data,
+				TransformSpec: tt.transformSpec,
+			}})
+			if err != nil {
+				t.Fatalf("client.UploadFile: %s", err)
+			}
+			modified := buf.m["modified_upload"]
+			var got any
+			switch {
+			case tt.transformSpec != "":
+				err = json.Unmarshal(modified, &got)
+			case tt.mimeType == "application/x-msgpack":
+				err = msgpack.Unmarshal(modified, &got)
+			default:
+				if !isCBOR(modified) {
+					t.Errorf("expected modified copy to still be tagged as CBOR, got %x", modified)
+				}
+				err = cbor.Unmarshal(modified, &got)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := deep.Equal(got, tt.want); diff != nil {
+				t.Errorf("%v", diff)
+			}
+		})
+	}
+}
+
+// replaces every json.Number with an int64 or float64
+func decodedNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = decodedNumbers(elem)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = decodedNumbers(elem)
+		}
+	}
+	return v
+}
+
+func mustMarshal(t *testing.T, marshal func(any) ([]byte, error), v any) []byte {
+	data, err := marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
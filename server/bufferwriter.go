@@ -2,25 +2,49 @@ package main
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"strings"
+	"sync"
 )
 
 // quick bootstrap of bytes.Buffer to use for testing
 type bufwc struct {
 	buffer  *bytes.Buffer
-	m       map[string][]byte // quasi in-memory database of "files"
+	m       map[string][]byte // quasi in-memory database of "files", shared between sessions
+	mu      *sync.RWMutex     // guards m, shared between sessions
 	current string
+	policy  ConflictPolicy
+	stored  string // key the last "file" was stored under
+	kept    string // key the "file" it replaced was kept as
 }
 
 func NewBufferWriter() *bufwc {
 	buf := bufwc{}
 	buf.buffer = bytes.NewBuffer([]byte{})
 	buf.m = make(map[string][]byte)
+	buf.mu = &sync.RWMutex{}
 	return &buf
 }
 
 // Check interface conformity
-var _ OpenWriteCloserLoader = &bufwc{}
+var (
+	_ OpenWriteCloserLoader = &bufwc{}
+	_ WriterFactory         = &bufwc{}
+	_ Resumer               = &bufwc{}
+	_ ConflictResolver      = &bufwc{}
+)
+
+// returns a new session with its own buffer, backed by the same "database"
+func (b *bufwc) NewWriter() OpenWriteCloserLoader {
+	return &bufwc{
+		buffer: bytes.NewBuffer([]byte{}),
+		m:      b.m,
+		mu:     b.mu,
+	}
+}
 
 func (b *bufwc) Open(key string) error {
 	b.current = key
@@ -32,7 +56,7 @@ func (b *bufwc) Write(p []byte) (n int, err error) {
 	return b.buffer.Write(p)
 }
 
-// will save the content of the buffer into the "current" map entry
+// will save the content of the buffer into the "current" map entry (or wherever the conflict policy says)
 // then clear the contents of the bytes.Buffer to be reused
 func (b *bufwc) Close() error {
 	if b.buffer.Len() == 0 {
@@ -44,30 +68,112 @@ func (b *bufwc) Close() error {
 	if n == 0 {
 		log.Fatalf("no bytes copied from current buffer!")
 	}
-	// save in the "database"
-	b.m[b.current] = data
 	// clear the contents of the buffer
 	b.buffer.Reset()
+	// save in the "database"
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if strings.HasPrefix(b.current, partialPrefix) {
+		// stays put until committed
+		b.m[b.current] = data
+		return nil
+	}
+	return b.store(data, b.current)
+}
+
+func (b *bufwc) SetConflictPolicy(policy ConflictPolicy) {
+	b.policy = policy
+}
+
+func (b *bufwc) Stored() (string, string) {
+	return b.stored, b.kept
+}
+
+// saves data under `key`, or wherever the conflict policy says; b.mu must be held
+func (b *bufwc) store(data []byte, key string) error {
+	policy := b.policy
+	b.policy = Overwrite
+	stored, kept, err := resolveConflict(key, policy, func(k string) (bool, error) {
+		_, ok := b.m[k]
+		return ok, nil
+	})
+	if err != nil {
+		return err
+	}
+	if kept != "" {
+		b.m[kept] = b.m[key]
+	}
+	b.m[stored] = data
+	b.stored, b.kept = stored, kept
 	return nil
 }
 
-func (b *bufwc) Load(key string) ([]byte, error) {
-	// return copy of data value of the matching key in database, perform modifications
+// clears the buffer without saving it, unless it's a partial upload, which keeps what it has
+func (b *bufwc) Abort() error {
+	if strings.HasPrefix(b.current, partialPrefix) {
+		return b.Close()
+	}
+	b.buffer.Reset()
+	return nil
+}
+
+func (b *bufwc) Load(key string) (io.ReadCloser, error) {
+	// return reader over the data value of the matching key in database
+	// (safe without copying: Close always stores a new slice, never mutates an existing one)
+	b.mu.RLock()
 	value, ok := b.m[key]
+	b.mu.RUnlock()
 	if !ok {
-		// being lazy
-		log.Fatalf("no such entry '%s' to load", key)
+		return nil, fmt.Errorf("no such entry '%s' to load: %w", key, fs.ErrNotExist)
 	}
-	data := make([]byte, len(value))
-	n := copy(data, value)
-	if n == 0 {
-		log.Fatalf("no bytes copied for entry with key '%s'", key)
+	return io.NopCloser(bytes.NewReader(value)), nil
+}
+
+// partially received resumable uploads are stored under this key prefix until completed
+const partialPrefix = ".partial/"
+
+func (b *bufwc) Offset(uploadID string) (int64, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return int64(len(b.m[partialPrefix+uploadID])), nil
+}
+
+func (b *bufwc) Resume(uploadID string, offset int64) error {
+	b.current = partialPrefix + uploadID
+	b.mu.RLock()
+	value := b.m[b.current]
+	b.mu.RUnlock()
+	if offset > int64(len(value)) {
+		return fmt.Errorf("offset %d beyond end of '%s'", offset, b.current)
+	}
+	// start the buffer off with everything received so far, up to the offset
+	b.buffer = bytes.NewBuffer(append([]byte{}, value[:offset]...))
+	return nil
+}
+
+func (b *bufwc) Commit(uploadID, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, ok := b.m[partialPrefix+uploadID]
+	if !ok {
+		return fmt.Errorf("no such partial upload '%s': %w", uploadID, fs.ErrNotExist)
+	}
+	if err := b.store(value, key); err != nil {
+		return err
 	}
-	return data, nil
+	delete(b.m, partialPrefix+uploadID)
+	return nil
+}
+
+func (b *bufwc) Discard(uploadID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.m, partialPrefix+uploadID)
+	return nil
 }
 
-func (b *bufwc) loadCurrent() ([]byte, error) {
-	return b.Load(b.current)
+func (b *bufwc) LoadPartial(uploadID string) (io.ReadCloser, error) {
+	return b.Load(partialPrefix + uploadID)
 }
 
 func (b *bufwc) String() string {
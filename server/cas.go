@@ -0,0 +1,346 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// where a casWriter keeps the contents of files, and the refs from file names to them
+const (
+	blobsDir = ".blobs"
+	refsDir  = ".refs"
+)
+
+/**
+ * casWriter is a content-addressed diskWriter: the contents of every file are stored as a blob
+ * named after its SHA-256 digest, with a ref from the file name to it, so the same content
+ * uploaded again (under whatever name) takes up no more disk space. Beneath its directory:
+ *  - `.blobs/ab/abcd...`: blobs by digest, split up by the first byte so no directory gets too big
+ *  - `.refs/kiwi.json`: the digest of the blob with the contents of `kiwi.json`
+ * The digest is worked out as a file is written (or for a resumable upload, once it's complete).
+ *
+ * Blobs are removed as soon as nothing refers to them any more, and any left behind (if the
+ * server stopped in between, or removing one failed) are swept up by CollectGarbage: when the
+ * store is opened, and every so often after that with WithGarbageCollection.
+ */
+type casWriter struct {
+	*diskWriter
+	refs   map[string]int // number of refs to each blob, shared between sessions, guarded by mu
+	digest hash.Hash      // of the file being written
+}
+
+// opens (creating it if need be) a casWriter on the directory, collecting any garbage in it
+func newCASWriter(dir string) (*casWriter, error) {
+	dw, err := newDiskWriter(dir)
+	if err != nil {
+		return nil, err
+	}
+	cw := &casWriter{diskWriter: dw, refs: make(map[string]int)}
+	if err := cw.init(); err != nil {
+		dw.root.Close()
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *casWriter) init() error {
+	for _, dir := range []string{blobsDir, refsDir} {
+		if err := cw.root.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	d, err := cw.root.Open(refsDir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		digest, err := cw.readRef(name)
+		if err != nil {
+			return err
+		}
+		cw.refs[digest]++
+	}
+	_, err = cw.CollectGarbage()
+	return err
+}
+
+// Check interface conformity
+var (
+	_ OpenWriteCloserLoader = &casWriter{}
+	_ WriterFactory         = &casWriter{}
+	_ Resumer               = &casWriter{}
+	_ Locator               = &casWriter{}
+	_ ConflictResolver      = &casWriter{}
+	_ GarbageCollector      = &casWriter{}
+)
+
+// returns a new casWriter session writing to the same directory
+func (cw *casWriter) NewWriter() OpenWriteCloserLoader {
+	return &casWriter{diskWriter: cw.diskWriter.NewWriter().(*diskWriter), refs: cw.refs}
+}
+
+func (cw *casWriter) Open(filename string) error {
+	if err := cw.diskWriter.Open(filename); err != nil {
+		return err
+	}
+	cw.digest = sha256.New()
+	return nil
+}
+
+func (cw *casWriter) Write(p []byte) (int, error) {
+	n, err := cw.f.Write(p)
+	if cw.digest != nil {
+		// resumed uploads aren't written in one go, they're hashed once complete
+		cw.digest.Write(p[:n])
+	}
+	return n, err
+}
+
+func (cw *casWriter) Close() error {
+	if err := cw.closeFile(); err != nil || cw.tmp == "" {
+		return err
+	}
+	if err := cw.store(cw.tmp, cw.name, hex.EncodeToString(cw.digest.Sum(nil))); err != nil {
+		return err
+	}
+	cw.tmp, cw.digest = "", nil
+	return nil
+}
+
+func (cw *casWriter) Commit(uploadID, filename string) error {
+	if err := cw.checkPath(refPath(filename)); err != nil {
+		return err
+	}
+	partial := cw.partialPath(uploadID)
+	f, err := cw.root.Open(partial)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	digest := sha256.New()
+	if _, err := io.Copy(digest, f); err != nil {
+		return err
+	}
+	return cw.store(partial, filename, hex.EncodeToString(digest.Sum(nil)))
+}
+
+func blobPath(digest string) string {
+	return path.Join(blobsDir, digest[:2], digest)
+}
+
+func refPath(filename string) string {
+	return path.Join(refsDir, filename)
+}
+
+// moves a complete file into place as the blob `digest` (unless it's already there),
+// and points the ref for `filename` at it, or for wherever the conflict policy says
+func (cw *casWriter) store(from, filename, digest string) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	policy := cw.policy
+	cw.policy = Overwrite
+	stored, kept, err := resolveConflict(filename, policy, func(name string) (bool, error) {
+		_, err := cw.readRef(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return err
+	}
+	blob := blobPath(digest)
+	if _, err := cw.root.Lstat(blob); err == nil {
+		log.Printf("'%s' is a duplicate of blob %s\n", stored, digest)
+		if err := cw.root.Remove(from); err != nil {
+			return err
+		}
+	} else if errors.Is(err, fs.ErrNotExist) {
+		if err := cw.root.MkdirAll(path.Dir(blob), os.ModePerm); err != nil {
+			return err
+		}
+		if err := cw.root.Rename(from, blob); err != nil {
+			return err
+		}
+		if err := cw.syncDir(path.Dir(blob)); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+	previous, err := cw.readRef(stored)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if kept != "" {
+		// keeping a file costs no more than a ref
+		if err := cw.writeRef(kept, previous); err != nil {
+			return err
+		}
+		cw.refs[previous]++
+	}
+	if err := cw.writeRef(stored, digest); err != nil {
+		return err
+	}
+	cw.refs[digest]++
+	cw.stored, cw.kept = stored, kept
+	if previous != "" {
+		cw.unref(previous)
+	}
+	return nil
+}
+
+// drops a ref to a blob, removing it if that was the last; cw.mu must be held
+func (cw *casWriter) unref(digest string) {
+	cw.refs[digest]--
+	if cw.refs[digest] > 0 {
+		return
+	}
+	delete(cw.refs, digest)
+	log.Printf("removing unreferenced blob %s\n", digest)
+	if err := cw.root.Remove(blobPath(digest)); err != nil {
+		// it'll be collected next time
+		log.Printf("failed to remove blob %s: %s", digest, err)
+	}
+}
+
+// the digest of the blob a file refers to, or an error wrapping fs.ErrNotExist if there's no such file
+func (cw *casWriter) readRef(filename string) (string, error) {
+	data, err := cw.root.ReadFile(refPath(filename))
+	if err != nil {
+		return "", err
+	}
+	digest := strings.TrimSpace(string(data))
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("ref '%s' is corrupt: %q", filename, data)
+	}
+	return digest, nil
+}
+
+// replaces the ref for a file in one go, so it's never left half written
+func (cw *casWriter) writeRef(filename, digest string) error {
+	f, tmp, err := cw.createTemp()
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(digest + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = cw.root.Rename(tmp, refPath(filename))
+	}
+	if err != nil {
+		cw.root.Remove(tmp)
+		return err
+	}
+	return cw.syncDir(refsDir)
+}
+
+func (cw *casWriter) Load(filename string) (io.ReadCloser, error) {
+	if err := cw.checkPath(refPath(filename)); err != nil {
+		return nil, err
+	}
+	// open it before the blob can be removed
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	digest, err := cw.readRef(filename)
+	if err != nil {
+		return nil, err
+	}
+	return cw.root.Open(blobPath(digest))
+}
+
+// where the blob with the file's contents is
+func (cw *casWriter) Locate(filename string) string {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	digest, err := cw.readRef(filename)
+	if err != nil {
+		return cw.diskWriter.Locate(refPath(filename))
+	}
+	return filepath.Join(cw.root.Name(), blobPath(digest))
+}
+
+// CollectGarbage removes any blobs nothing refers to, returning how many there were;
+// uploads wait to be stored until it's done
+func (cw *casWriter) CollectGarbage() (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	removed := 0
+	err := fs.WalkDir(cw.root.FS(), blobsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if cw.refs[d.Name()] > 0 {
+			return nil
+		}
+		log.Printf("collecting unreferenced blob '%s'\n", p)
+		if err := cw.root.Remove(p); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// GarbageCollector is a WriterFactory which can be left holding data nothing refers to any more
+type GarbageCollector interface {
+	// CollectGarbage removes whatever nothing refers to, returning how much of it there was
+	CollectGarbage() (int, error)
+}
+
+// WithGarbageCollection collects garbage every `interval` until the Uploader is stopped,
+// if its writers are a GarbageCollector; otherwise there's nothing to do
+func WithGarbageCollection(interval time.Duration) UploaderOption {
+	return func(u *Uploader) {
+		gc, ok := u.io_thingee.(GarbageCollector)
+		if !ok {
+			return
+		}
+		stop, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if removed, err := gc.CollectGarbage(); err != nil {
+						// it'll be tried again next time
+						log.Printf("failed to collect garbage: %s", err)
+					} else if removed > 0 {
+						log.Printf("collected %d pieces of garbage\n", removed)
+					}
+				case <-stop:
+					return
+				}
+			}
+		}()
+		u.stopGC = sync.OnceFunc(func() {
+			close(stop)
+			<-done
+		})
+	}
+}
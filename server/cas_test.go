@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+)
+
+// the digests of the blobs stored in dir, and how many bytes they take up between them
+func storedBlobs(t *testing.T, dir string) ([]string, int64) {
+	t.Helper()
+	var digests []string
+	var size int64
+	err := filepath.WalkDir(filepath.Join(dir, blobsDir), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		digests = append(digests, d.Name())
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(digests)
+	return digests, size
+}
+
+func digestOf(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestUploaderService_Dedupe(t *testing.T) {
+	dir := t.TempDir()
+	cw, err := newCASWriter(dir)
+	client, _ := newTestClient(t, cw, err)
+	artefact := strings.Repeat("the same old build artefact\n", 10000)
+	checkBlobs := func(want ...string) {
+		t.Helper()
+		var wantDigests []string
+		var wantSize int64
+		for _, data := range want {
+			wantDigests = append(wantDigests, digestOf(data))
+			wantSize += int64(len(data))
+		}
+		slices.Sort(wantDigests)
+		if got, size := storedBlobs(t, dir); !slices.Equal(got, wantDigests) || size != wantSize {
+			t.Errorf("expected blobs %v of %d bytes in all, got %v of %d bytes", wantDigests, wantSize, got, size)
+		}
+	}
+	checkContents := func(name, want string) {
+		t.Helper()
+		got, err := receiveDataFromServer(t, client, name, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("expected %s to contain %d bytes, got %d", name, len(want), len(got))
+		}
+	}
+
+	// the same content under different names, resumable or not, is only stored once
+	var paths []string
+	for _, name := range []string{"a.bin", "b.bin"} {
+		resp, err := sendDataInChunksToServer(t, client, artefact, name, "application/octet-stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, resp.StoredPath)
+	}
+	resp, err := sendResumableDataInChunksToServer(t, client, artefact, "c.bin", "application/octet-stream", "c", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths, resp.StoredPath)
+	wantPath := filepath.Join(dir, blobPath(digestOf(artefact)))
+	for _, p := range paths {
+		if p != wantPath {
+			t.Errorf("expected every upload to be stored at %s, got %s", wantPath, p)
+		}
+	}
+	checkBlobs(artefact)
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		checkContents(name, artefact)
+	}
+
+	// a blob is kept as long as anything refers to it
+	if _, err := sendDataInChunksToServer(t, client, "something else", "a.bin", "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+	checkBlobs(artefact, "something else")
+	for _, name := range []string{"b.bin", "c.bin"} {
+		if _, err := sendDataInChunksToServer(t, client, "something else", name, "application/octet-stream"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkBlobs("something else")
+
+	// keeping the file that was there takes no more than a ref
+	resp, err = sendRequestsToServer(t, client, []*uploadpb.UploadRequest{
+		{FileName: "a.bin", Chunk: []byte("another thing"), ConflictPolicy: uploadpb.ConflictPolicy_CONFLICT_POLICY_KEEP_VERSIONS},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.KeptFileName != "a.bin.~1~" {
+		t.Errorf("expected a.bin to be kept as a.bin.~1~, got %q", resp.KeptFileName)
+	}
+	checkBlobs("something else", "another thing")
+	checkContents("a.bin", "another thing")
+	checkContents("a.bin.~1~", "something else")
+}
+
+func TestCASWriter_CollectGarbage(t *testing.T) {
+	dir := t.TempDir()
+	cw, err := newCASWriter(dir)
+	client, _ := newTestClient(t, cw, err)
+	for _, name := range []string{"kiwi.txt", "feijoa.txt"} {
+		if _, err := sendDataInChunksToServer(t, client, "fruit", name, "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// as if the server had stopped before it could remove a blob
+	stray := filepath.Join(dir, blobPath(digestOf("stray")))
+	if err := os.MkdirAll(filepath.Dir(stray), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stray, []byte("stray"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := cw.CollectGarbage(); err != nil || removed != 1 {
+		t.Errorf("expected the stray blob to be collected, removed %d (%v)", removed, err)
+	}
+	if err := os.WriteFile(stray, []byte("stray"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cw.root.Close()
+
+	// and again when the store is reopened, which keeps track of what refers to what
+	cw, err = newCASWriter(dir)
+	client, _ = newTestClient(t, cw, err)
+	if got, _ := storedBlobs(t, dir); !slices.Equal(got, []string{digestOf("fruit")}) {
+		t.Errorf("expected only the blob for 'fruit' to be left, got %v", got)
+	}
+	if _, err := sendDataInChunksToServer(t, client, "kiwifruit", "kiwi.txt", "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{digestOf("fruit"), digestOf("kiwifruit")}
+	slices.Sort(want)
+	if got, _ := storedBlobs(t, dir); !slices.Equal(got, want) {
+		t.Errorf("expected the blob for 'fruit' to be kept for feijoa.txt, got %v", got)
+	}
+	if _, err := sendDataInChunksToServer(t, client, "feijoa", "feijoa.txt", "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{digestOf("feijoa"), digestOf("kiwifruit")}
+	slices.Sort(want)
+	if got, _ := storedBlobs(t, dir); !slices.Equal(got, want) {
+		t.Errorf("expected the blob for 'fruit' to be removed once nothing refers to it, got %v", got)
+	}
+}
+
+// blobs left behind while the server's running don't have to wait for it to restart
+func TestCASWriter_CollectGarbage_Periodically(t *testing.T) {
+	dir := t.TempDir()
+	cw, err := newCASWriter(dir)
+	client, uploadSvc := newTestClient(t, cw, err, WithGarbageCollection(10*time.Millisecond))
+	if _, err := sendDataInChunksToServer(t, client, "fruit", "kiwi.txt", "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	// as if removing it had failed once nothing referred to it
+	stray := filepath.Join(dir, blobPath(digestOf("stray")))
+	if err := os.MkdirAll(filepath.Dir(stray), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stray, []byte("stray"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := storedBlobs(t, dir)
+		if slices.Equal(got, []string{digestOf("fruit")}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the stray blob to be collected, got %v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// and stopping the uploader stops collection (more than once is fine)
+	uploadSvc.Stop()
+	uploadSvc.Stop()
+	if err := os.WriteFile(stray, []byte("stray"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(stray); err != nil {
+		t.Errorf("expected no collection once stopped, got: %v", err)
+	}
+}
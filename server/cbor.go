@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ProcessCBOR is ProcessJSON for CBOR (RFC 8949) uploads, including CBOR sequences; see binary.go
+func ProcessCBOR(filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error) {
+	err := processFile(filename, x, func(r io.Reader, w io.Writer) error {
+		return transformBinary(&cborFormat{dec: cborDecMode.NewDecoder(r)}, w, spec.rules(), spec.outputJSON())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write modified CBOR data to file: %w", err)
+	}
+	return &ProcessReport{}, nil
+}
+
+// same limit on nesting as JSON, and no limit on size, beyond the package's own
+var cborDecMode = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{
+		MaxNestedLevels:  maxJSONDepth,
+		MaxArrayElements: math.MaxInt32,
+		MaxMapPairs:      math.MaxInt32,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}()
+
+type cborFormat struct {
+	dec *cbor.Decoder
+	// whether the current top-level item is marked as CBOR with the self-described tag
+	selfDescribed bool
+}
+
+func (f *cborFormat) next() (any, error) {
+	var raw cbor.RawMessage
+	read := f.dec.NumBytesRead()
+	if err := f.dec.Decode(&raw); err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("%w cbor data: %w", ErrInvalidData, err)
+	}
+	// the decoder drops the tag from the raw item, so anything more than that which
+	// got read must have been the tag; it says nothing about what's inside, so
+	// the rules still apply to it
+	f.selfDescribed = f.dec.NumBytesRead()-read > len(raw)
+	return cborTree(raw)
+}
+
+// CBOR major types
+const (
+	cborArray = 4
+	cborMap   = 5
+	cborTag   = 6
+)
+
+// decodes a single, well-formed CBOR data item into a tree
+func cborTree(item []byte) (any, error) {
+	major := item[0] >> 5
+	if major != cborArray && major != cborMap {
+		var native any
+		if err := cborDecMode.Unmarshal(item, &native); err != nil {
+			return nil, fmt.Errorf("%w cbor data: %w", ErrInvalidData, err)
+		}
+		if major == cborTag {
+			// only bignums are numbers, every other tag is left as it is
+			if n, ok := native.(big.Int); ok {
+				return newTreeScalar(item, n), nil
+			}
+			s := newTreeScalar(item, native)
+			s.v = s
+			return s, nil
+		}
+		return newTreeScalar(item, native), nil
+	}
+	n, indefinite, rest := cborHeader(item)
+	if major == cborMap {
+		// keys and values
+		n *= 2
+	}
+	var items []any
+	for i := uint64(0); ; i++ {
+		if indefinite && rest[0] == 0xff {
+			// "break"
+			break
+		}
+		if !indefinite && i == n {
+			break
+		}
+		var next cbor.RawMessage
+		var err error
+		if rest, err = cborDecMode.UnmarshalFirst(rest, &next); err != nil {
+			return nil, fmt.Errorf("%w cbor data: %w", ErrInvalidData, err)
+		}
+		v, err := cborTree(next)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	if major == cborArray {
+		if items == nil {
+			items = []any{}
+		}
+		return items, nil
+	}
+	m := &treeMap{}
+	for i := 0; i+1 < len(items); i += 2 {
+		m.keys, m.values = append(m.keys, items[i]), append(m.values, items[i+1])
+	}
+	return m, nil
+}
+
+// parses the head of an array or map: its length (unless indefinite) and everything after it;
+// only for items already known to be well-formed
+func cborHeader(item []byte) (n uint64, indefinite bool, rest []byte) {
+	switch info := item[0] & 0x1f; {
+	case info < 24:
+		return uint64(info), false, item[1:]
+	case info == 24:
+		return uint64(item[1]), false, item[2:]
+	case info == 25:
+		return uint64(binary.BigEndian.Uint16(item[1:])), false, item[3:]
+	case info == 26:
+		return uint64(binary.BigEndian.Uint32(item[1:])), false, item[5:]
+	case info == 27:
+		return binary.BigEndian.Uint64(item[1:]), false, item[9:]
+	}
+	return 0, true, item[1:]
+}
+
+// writes the head of an array or map of length n
+func appendCBORHeader(b []byte, major byte, n int) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= 0xff:
+		return append(b, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), uint64(n))
+}
+
+func (f *cborFormat) encode(w io.Writer, v any) error {
+	if f.selfDescribed {
+		if _, err := w.Write(cborMagic); err != nil {
+			return err
+		}
+	}
+	return f.encodeTree(w, v)
+}
+
+func (f *cborFormat) encodeTree(w io.Writer, v any) error {
+	switch v := v.(type) {
+	case *treeMap:
+		if _, err := w.Write(appendCBORHeader(nil, cborMap, len(v.keys))); err != nil {
+			return err
+		}
+		for i, k := range v.keys {
+			if err := f.encodeTree(w, k); err != nil {
+				return err
+			}
+			if err := f.encodeTree(w, v.values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		if _, err := w.Write(appendCBORHeader(nil, cborArray, len(v))); err != nil {
+			return err
+		}
+		for _, elem := range v {
+			if err := f.encodeTree(w, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *treeScalar:
+		if v.raw == nil {
+			var err error
+			if v.raw, err = cbor.Marshal(v.native); err != nil {
+				return err
+			}
+		}
+		_, err := w.Write(v.raw)
+		return err
+	}
+	return errors.New("unexpected value in tree")
+}
+
+// the self-described CBOR tag (55799), which marks data as CBOR
+var cborMagic = []byte{0xd9, 0xd9, 0xf7}
+
+func isCBOR(data []byte) bool {
+	return bytes.HasPrefix(data, cborMagic)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+)
+
+// ConflictPolicy says what to do when an upload's file name is already taken
+type ConflictPolicy int
+
+const (
+	// replace the file already there (the default)
+	Overwrite ConflictPolicy = iota
+	// leave the file already there, and fail with ErrFileExists
+	FailOnConflict
+	// store the upload under the next free name instead: `kiwi (1).json`, `kiwi (2).json`...
+	RenameOnConflict
+	// replace the file already there, but keep it as the next free version: `kiwi.json.~1~`...
+	KeepVersions
+)
+
+var conflictPolicies = map[uploadpb.ConflictPolicy]ConflictPolicy{
+	uploadpb.ConflictPolicy_CONFLICT_POLICY_UNSPECIFIED:   Overwrite,
+	uploadpb.ConflictPolicy_CONFLICT_POLICY_OVERWRITE:     Overwrite,
+	uploadpb.ConflictPolicy_CONFLICT_POLICY_FAIL:          FailOnConflict,
+	uploadpb.ConflictPolicy_CONFLICT_POLICY_RENAME:        RenameOnConflict,
+	uploadpb.ConflictPolicy_CONFLICT_POLICY_KEEP_VERSIONS: KeepVersions,
+}
+
+// ErrFileExists is a file name already taken, when the ConflictPolicy says not to replace it
+var ErrFileExists = errors.New("file already exists")
+
+// ConflictResolver is implemented by writers which can do something other than
+// replace a file already stored under the name of the file being written
+type ConflictResolver interface {
+	// SetConflictPolicy sets how the next file to be stored (on Close, or Commit for
+	// a resumable upload) is dealt with if its name is taken; after that it's back to Overwrite
+	SetConflictPolicy(ConflictPolicy)
+	// Stored returns the name the last file was stored under, and the name
+	// the file it replaced was kept as, if it was
+	Stored() (name, kept string)
+}
+
+// give up looking for a free name after this many
+const maxConflictSuffix = 10000
+
+/**
+ * resolveConflict works out where a file is to be stored as per the policy, given a way to tell
+ * whether a name is already taken: the name to store it under, and the name to move the file
+ * already there to, if it's to be kept (moving it is up to the caller, before storing the new one).
+ * Names are checked one after the other, so the caller has to stop anything else storing
+ * files in the meantime.
+ */
+func resolveConflict(name string, policy ConflictPolicy, taken func(string) (bool, error)) (stored, kept string, err error) {
+	if policy == Overwrite {
+		return name, "", nil
+	}
+	exists, err := taken(name)
+	if err != nil || !exists {
+		return name, "", err
+	}
+	nextFree := func(nameFor func(i int) string) (string, error) {
+		for i := 1; i <= maxConflictSuffix; i++ {
+			candidate := nameFor(i)
+			if err := checkFileName(candidate); err != nil {
+				return "", err
+			}
+			if exists, err := taken(candidate); err != nil || !exists {
+				return candidate, err
+			}
+		}
+		return "", fmt.Errorf("%w: no free name left for '%s'", ErrFileExists, name)
+	}
+	switch policy {
+	case FailOnConflict:
+		return "", "", fmt.Errorf("%w: '%s'", ErrFileExists, name)
+	case RenameOnConflict:
+		stored, err := nextFree(func(i int) string { return renamedName(name, i) })
+		return stored, "", err
+	case KeepVersions:
+		kept, err := nextFree(func(i int) string { return versionName(name, i) })
+		return name, kept, err
+	}
+	return "", "", fmt.Errorf("unknown conflict policy %d", policy)
+}
+
+// `kiwi.json` becomes `kiwi (1).json`, keeping the extension, so the type can still be told by it
+func renamedName(name string, i int) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		// all extension, e.g. `.env`
+		ext = ""
+	}
+	return fmt.Sprintf("%s (%d)%s", name[:len(name)-len(ext)], i, ext)
+}
+
+// `kiwi.json` is kept as `kiwi.json.~1~`, like numbered backups from `cp --backup=numbered`
+func versionName(name string, i int) string {
+	return fmt.Sprintf("%s.~%d~", name, i)
+}
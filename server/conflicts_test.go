@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestResolveConflict(t *testing.T) {
+	taken := map[string]bool{
+		"kiwi.json": true, "kiwi (1).json": true, "kiwi.json.~1~": true,
+		".env": true, "kiwi.tar.gz": true, "kiwi": true,
+	}
+	isTaken := func(name string) (bool, error) { return taken[name], nil }
+	cases := []struct {
+		name       string
+		policy     ConflictPolicy
+		wantStored string
+		wantKept   string
+		wantErr    error
+	}{
+		{"feijoa.json", FailOnConflict, "feijoa.json", "", nil},
+		{"feijoa.json", RenameOnConflict, "feijoa.json", "", nil},
+		{"feijoa.json", KeepVersions, "feijoa.json", "", nil},
+		{"kiwi.json", Overwrite, "kiwi.json", "", nil},
+		{"kiwi.json", FailOnConflict, "", "", ErrFileExists},
+		{"kiwi.json", RenameOnConflict, "kiwi (2).json", "", nil},
+		{"kiwi.json", KeepVersions, "kiwi.json", "kiwi.json.~2~", nil},
+		{".env", RenameOnConflict, ".env (1)", "", nil},
+		{"kiwi.tar.gz", RenameOnConflict, "kiwi.tar (1).gz", "", nil},
+		{"kiwi", RenameOnConflict, "kiwi (1)", "", nil},
+		{"kiwi", KeepVersions, "kiwi", "kiwi.~1~", nil},
+	}
+	for _, tt := range cases {
+		stored, kept, err := resolveConflict(tt.name, tt.policy, isTaken)
+		if !errors.Is(err, tt.wantErr) || stored != tt.wantStored || kept != tt.wantKept {
+			t.Errorf("%s with policy %d: expected (%q, %q, %v), got (%q, %q, %v)", tt.name, tt.policy,
+				tt.wantStored, tt.wantKept, tt.wantErr, stored, kept, err)
+		}
+	}
+
+	// no room left for a suffix
+	long := strings.Repeat("a", maxFileNameLen)
+	taken[long] = true
+	if _, _, err := resolveConflict(long, RenameOnConflict, isTaken); !errors.Is(err, ErrUnsafeFileName) {
+		t.Errorf("expected renaming a name of the maximum length to fail, got: %v", err)
+	}
+}
+
+func TestUploaderService_UploadFile_ConflictPolicies(t *testing.T) {
+	writers := map[string]func(t *testing.T) WriterFactory{
+		"buffer": func(t *testing.T) WriterFactory { return NewBufferWriter() },
+		"disk":   func(t *testing.T) WriterFactory { return newTempDiskWriter(t) },
+	}
+	for writerName, newWriter := range writers {
+		t.Run(writerName, func(t *testing.T) {
+			uploadSvc := NewCustomUploader(newWriter(t))
+			conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+				uploadpb.RegisterUploaderServer(srv, uploadSvc)
+			})
+			client := uploadpb.NewUploaderClient(conn)
+
+			upload := func(data string, policy uploadpb.ConflictPolicy, uploadID string) (*uploadpb.UploadResponse, error) {
+				return sendRequestsToServer(t, client, []*uploadpb.UploadRequest{
+					{FileName: "kiwi.txt", Chunk: []byte(data), ConflictPolicy: policy, UploadId: uploadID},
+				})
+			}
+			check := func(resp *uploadpb.UploadResponse, err error, wantName, wantKept string) {
+				t.Helper()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.FileName != wantName || resp.KeptFileName != wantKept {
+					t.Errorf("expected to be stored as %q keeping %q, got %q keeping %q", wantName, wantKept, resp.FileName, resp.KeptFileName)
+				}
+			}
+			checkContents := func(name, want string) {
+				t.Helper()
+				got, err := receiveDataFromServer(t, client, name, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("expected %s to contain %q, got %q", name, want, got)
+				}
+			}
+
+			resp, err := upload("one", uploadpb.ConflictPolicy_CONFLICT_POLICY_FAIL, "")
+			check(resp, err, "kiwi.txt", "")
+
+			_, err = upload("two", uploadpb.ConflictPolicy_CONFLICT_POLICY_FAIL, "")
+			checkDetails(t, err, wantDetails{code: codes.AlreadyExists, resource: "kiwi.txt", reason: ReasonFileExists})
+			checkContents("kiwi.txt", "one")
+
+			resp, err = upload("three", uploadpb.ConflictPolicy_CONFLICT_POLICY_RENAME, "")
+			check(resp, err, "kiwi (1).txt", "")
+			resp, err = upload("four", uploadpb.ConflictPolicy_CONFLICT_POLICY_RENAME, "renamed")
+			check(resp, err, "kiwi (2).txt", "")
+			checkContents("kiwi.txt", "one")
+			checkContents("kiwi (1).txt", "three")
+			checkContents("kiwi (2).txt", "four")
+
+			resp, err = upload("five", uploadpb.ConflictPolicy_CONFLICT_POLICY_KEEP_VERSIONS, "")
+			check(resp, err, "kiwi.txt", "kiwi.txt.~1~")
+			resp, err = upload("six", uploadpb.ConflictPolicy_CONFLICT_POLICY_KEEP_VERSIONS, "kept")
+			check(resp, err, "kiwi.txt", "kiwi.txt.~2~")
+			checkContents("kiwi.txt", "six")
+			checkContents("kiwi.txt.~1~", "one")
+			checkContents("kiwi.txt.~2~", "five")
+
+			resp, err = upload("seven", uploadpb.ConflictPolicy_CONFLICT_POLICY_UNSPECIFIED, "")
+			check(resp, err, "kiwi.txt", "")
+			checkContents("kiwi.txt", "seven")
+
+			_, err = upload("eight", uploadpb.ConflictPolicy(99), "")
+			checkDetails(t, err, wantDetails{code: codes.InvalidArgument, field: "conflict_policy"})
+
+			// names the server makes up can't be taken over by an upload
+			for _, name := range []string{"kiwi.txt.~1~", "modified_kiwi.txt"} {
+				_, err = sendRequestsToServer(t, client, []*uploadpb.UploadRequest{{FileName: name, Chunk: []byte("nine")}})
+				checkDetails(t, err, wantDetails{code: codes.InvalidArgument, field: "file_name"})
+			}
+			checkContents("kiwi.txt.~1~", "one")
+		})
+	}
+}
+
+func TestUploaderService_UploadFile_ConflictPolicyUnsupported(t *testing.T) {
+	uploadSvc := NewCustomUploader(&discardWriter{written: new(atomic.Uint64)})
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	_, err := sendRequestsToServer(t, client, []*uploadpb.UploadRequest{
+		{FileName: "kiwi.txt", Chunk: []byte("kiwi"), ConflictPolicy: uploadpb.ConflictPolicy_CONFLICT_POLICY_RENAME},
+	})
+	checkDetails(t, err, wantDetails{code: codes.Unimplemented, field: "conflict_policy"})
+}
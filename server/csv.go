@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/**
+ * ProcessCSV is ProcessJSON for CSV (and tab-separated) uploads with a header row.
+ * Each row is treated like an object, with the headers as its keys: columns are dropped or
+ * renamed by the rules for their header, and each cell goes through the rules with the
+ * path of its (original) header. Cells which look like numbers are json.Number, the rest strings.
+ *
+ * Rows are streamed through one at a time, so memory use is bounded by the longest row,
+ * not the size of the file. A row which can't be read or transformed (e.g. with the wrong
+ * number of cells) is left out of the modified copy and reported, like a bad NDJSON line.
+ */
+func ProcessCSV(filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error) {
+	return processDelimited(filename, x, spec, ',')
+}
+
+// ProcessTSV is ProcessCSV for tab-separated values
+func ProcessTSV(filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error) {
+	return processDelimited(filename, x, spec, '\t')
+}
+
+func processDelimited(filename string, x OpenWriteCloserLoader, spec *TransformSpec, comma rune) (*ProcessReport, error) {
+	report := &ProcessReport{}
+	err := processFile(filename, x, func(r io.Reader, w io.Writer) error {
+		return transformCSV(r, w, spec.rules(), comma, report)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write modified CSV data to file: %w", err)
+	}
+	return report, nil
+}
+
+func transformCSV(r io.Reader, w io.Writer, rule Rule, comma rune, report *ProcessReport) error {
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.ReuseRecord = true
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		// nothing at all, nothing to do
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w csv data: %w", ErrInvalidData, err)
+	}
+	// the header decides which columns are kept, and what they're called
+	var keys Path
+	var columns []int
+	var newHeader []string
+	for i, key := range header {
+		if i == 0 {
+			// byte order mark, as written by spreadsheets
+			key = strings.TrimPrefix(key, "\ufeff")
+		}
+		keys = append(keys, key)
+		action, newKey := rule.Key(nil, key)
+		if action == DropKey {
+			continue
+		}
+		columns = append(columns, i)
+		newHeader = append(newHeader, newKey)
+	}
+	if err := cw.Write(newHeader); err != nil {
+		return err
+	}
+
+	row := make([]string, len(columns))
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			// only this row is bad, carry on with the next
+			report.lineFailed(parseErr.StartLine, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := transformRow(record, columns, keys, rule, row); err != nil {
+			line, _ := cr.FieldPos(0)
+			report.lineFailed(line, err)
+			continue
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// fills in `row` with the kept columns of `record`, as modified by the rule
+func transformRow(record []string, columns []int, keys Path, rule Rule, row []string) error {
+	for j, i := range columns {
+		path := keys[i : i+1]
+		var cell any = record[i]
+		if isJSONNumber(record[i]) {
+			cell = json.Number(record[i])
+		}
+		v, err := rule.Value(path, cell)
+		if err != nil {
+			return ruleFailed(path, err)
+		}
+		switch v := v.(type) {
+		case json.Number:
+			row[j] = v.String()
+		case string:
+			row[j] = v
+		case bool:
+			row[j] = strconv.FormatBool(v)
+		case nil:
+			row[j] = ""
+		default:
+			row[j] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+)
+
+func TestTransformCSV(t *testing.T) {
+	cases := []struct {
+		testName   string
+		spec       string
+		comma      rune
+		input      string
+		want       string
+		failedRows []int
+	}{
+		{
+			"default rules", "", ',',
+			"\ufeffid,name,age,kiwis,price\n1,Jane Doe,30,4,2.0\n2,\"Doe, John\",41,7,-12\n",
+			"name,kiwis,price\nJane Doe,4000,2.0\n\"Doe, John\",7,-12000\n",
+			nil,
+		},
+		{
+			"tab separated", "", '\t',
+			"apple\tfig\n1\t2\n",
+			"fig\n2000\n",
+			nil,
+		},
+		{
+			"bad rows are left out", "", ',',
+			"fig,kiwi\n2,4\n6\n8,\"10\"x\n12,14\n",
+			"fig,kiwi\n2000,4000\n12000,14000\n",
+			[]int{3, 4},
+		},
+		{
+			"rules failing a row", `{"rules": [{"rule": "scale-even-ints", "limit": "int32"}]}`, ',',
+			"fig\n2\n2000000000\n4\n",
+			"fig\n2000\n4000\n",
+			[]int{3},
+		},
+		{
+			"rename and uppercase", `{"rules": [{"rule": "rename-key", "from": "name", "to": "full name"}, {"rule": "uppercase-strings", "path": "name"}]}`, ',',
+			"name,city\njane,auckland\n",
+			"full name,city\nJANE,auckland\n",
+			nil,
+		},
+		{
+			"quoted numbers are still numbers", "", ',',
+			"fig\n\"2\"\n",
+			"fig\n2000\n",
+			nil,
+		},
+		{
+			"empty", "", ',',
+			"",
+			"",
+			nil,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			spec := &TransformSpec{Rules: DefaultRules()}
+			if tt.spec != "" {
+				var err error
+				if spec, err = ParseTransformSpec(tt.spec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var out strings.Builder
+			report := &ProcessReport{}
+			if err := transformCSV(strings.NewReader(tt.input), &out, spec.rules(), tt.comma, report); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, out.String())
+			}
+			if report.FailedLines != len(tt.failedRows) {
+				t.Fatalf("expected %d failed rows, got %d: %v", len(tt.failedRows), report.FailedLines, report.LineErrors)
+			}
+			for i, line := range tt.failedRows {
+				if report.LineErrors[i].Line != line {
+					t.Errorf("expected line %d to fail, got line %d", line, report.LineErrors[i].Line)
+				}
+			}
+		})
+	}
+}
+
+func TestUploaderService_UploadFile_CSV(t *testing.T) {
+	buf := NewBufferWriter()
+	uploadSvc := NewCustomUploader(buf)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	const data = "name,age,kiwis\nJane,30,4\nJohn,41\n"
+	resp, err := sendDataInChunksToServer(t, client, data, "people.csv", "text/csv")
+	if err != nil {
+		t.Fatalf("client.UploadFile: %s", err)
+	}
+	if got, want := string(buf.m["modified_people.csv"]), "name,kiwis\nJane,4000\n"; got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+	if resp.GetFailedLines() != 1 || resp.GetLineErrors()[0].GetLine() != 3 {
+		t.Errorf("expected line 3 to fail, got %v", resp.GetLineErrors())
+	}
+}
+
+func TestTransformCSV_Large(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large CSV file in short mode")
+	}
+	const rows = 2_000_000
+	// ~100 bytes a row
+	const header = "id,name,email,kiwis,orange,blurb\n"
+	const modifiedHeader = "name,kiwis,blurb\n"
+
+	// generate the input file on the fly, so the test itself never holds it in memory
+	pr, pw := io.Pipe()
+	go func() {
+		bw := bufio.NewWriter(pw)
+		bw.WriteString(header)
+		for i := 0; i < rows; i++ {
+			fmt.Fprintf(bw, "%d,Jane Doe,jane@example.com,%d,4,\"Lorem ipsum dolor sit amet, consectetur\"\n", i, i)
+		}
+		pw.CloseWithError(bw.Flush())
+	}()
+	// expected output, also built up on the fly
+	want := sha256.New()
+	want.Write([]byte(modifiedHeader))
+	for i := 0; i < rows; i++ {
+		kiwis := i
+		if i%2 == 0 {
+			kiwis *= 1000
+		}
+		fmt.Fprintf(want, "Jane Doe,%d,\"Lorem ipsum dolor sit amet, consectetur\"\n", kiwis)
+	}
+
+	// keep an eye on the heap while the transformation runs
+	got := sha256.New()
+	var err error
+	maxHeap := measurePeakHeap(t, func() {
+		err = transformCSV(pr, got, DefaultRules(), ',', &ProcessReport{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Sum(nil), want.Sum(nil)) {
+		t.Errorf("modified output does not match expected output")
+	}
+	t.Logf("peak heap: %d bytes", maxHeap)
+	const limit = 32 << 20
+	if maxHeap > limit {
+		t.Errorf("heap grew to %d bytes, expected less than %d", maxHeap, limit)
+	}
+}
@@ -1,49 +1,291 @@
 package main
 
 import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
-// default use - just a glorified wrapper around a call to `os.OpenFile(...)`
+// default use - just a glorified wrapper around a call to `os.OpenFile(...)`,
+// except every file is opened through an os.Root, so whatever the file name,
+// nothing is read or written outside of the directory (not even by way of a symlink)
+//
+// uploads are written to a temp file alongside the real one, only renamed
+// into place once they're complete and synced to disk
 type diskWriter struct {
-	f            *os.File
-	writeDirPath string
+	f      *os.File
+	root   *os.Root    // shared between sessions
+	mu     *sync.Mutex // shared between sessions, held while finding somewhere to put a file
+	name   string      // file name being written
+	tmp    string      // temp file being written to, until it's renamed to `name`
+	policy ConflictPolicy
+	stored string // name the last file was stored under
+	kept   string // name the file it replaced was kept as
+}
+
+// creates the directory if need be, for a diskWriter writing to it
+func newDiskWriter(dir string) (*diskWriter, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	dw := &diskWriter{root: root, mu: &sync.Mutex{}}
+	// nothing else is writing to the directory yet, so any temp files are left over from a crash
+	if err := dw.removeTempFiles(); err != nil {
+		root.Close()
+		return nil, err
+	}
+	return dw, nil
 }
 
 // Check interface conformity
-var _ OpenWriteCloserLoader = &diskWriter{}
+var (
+	_ OpenWriteCloserLoader = &diskWriter{}
+	_ WriterFactory         = &diskWriter{}
+	_ Resumer               = &diskWriter{}
+	_ Locator               = &diskWriter{}
+	_ ConflictResolver      = &diskWriter{}
+)
+
+// returns a new diskWriter session writing to the same directory
+func (dw *diskWriter) NewWriter() OpenWriteCloserLoader {
+	return &diskWriter{root: dw.root, mu: dw.mu}
+}
+
+// temp files are named with this prefix (which uploads can't use), followed by random characters
+const tempPrefix = ".tmp-"
 
 // uses the os package to open a file pointer so we can write bytes
-// to a file on disk with the given filename
+// to a temp file on disk, which becomes the given filename on Close
 func (dw *diskWriter) Open(filename string) error {
-	fp := dw.filePath(filename)
-	log.Printf("opening file '%s'\n", fp)
-	var err error
-	dw.f, err = os.OpenFile(fp, os.O_RDWR|os.O_CREATE, 0644)
-	return err
+	if err := dw.checkPath(filename); err != nil {
+		return err
+	}
+	log.Printf("opening file '%s'\n", dw.Locate(filename))
+	f, tmp, err := dw.createTemp()
+	if err != nil {
+		return err
+	}
+	dw.f, dw.name, dw.tmp = f, filename, tmp
+	return nil
+}
+
+// creates a new temp file in the directory, returning it along with its name
+func (dw *diskWriter) createTemp() (*os.File, string, error) {
+	for {
+		tmp := tempPrefix + rand.Text()
+		f, err := dw.root.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return f, tmp, err
+	}
 }
 
 func (dw *diskWriter) Write(p []byte) (int, error) {
 	return dw.f.Write(p)
 }
 
+// syncs the file to disk and closes it, then renames it into place (unless it's a
+// partial upload, which stays put until it's committed); if that fails, Abort cleans up
 func (dw *diskWriter) Close() error {
+	if err := dw.closeFile(); err != nil || dw.tmp == "" {
+		return err
+	}
+	if err := dw.store(dw.tmp, dw.name); err != nil {
+		return err
+	}
+	dw.tmp = ""
+	return nil
+}
+
+// syncs the file being written to disk, and closes it
+func (dw *diskWriter) closeFile() error {
+	if dw.f == nil {
+		// never opened (e.g. the upload was rejected before getting that far), or already closed
+		return nil
+	}
 	log.Println("closing file")
-	if err := dw.f.Close(); err != nil {
-		return ignoreErrorFileAlreadyClosed(err)
+	f := dw.f
+	dw.f = nil
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return ignoreErrorFileAlreadyClosed(f.Close())
+}
+
+func (dw *diskWriter) SetConflictPolicy(policy ConflictPolicy) {
+	dw.policy = policy
+}
+
+func (dw *diskWriter) Stored() (string, string) {
+	return dw.stored, dw.kept
+}
+
+// renames a complete file into place as `filename`, or wherever the conflict policy says
+func (dw *diskWriter) store(from, filename string) error {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	policy := dw.policy
+	dw.policy = Overwrite
+	stored, kept, err := resolveConflict(filename, policy, func(name string) (bool, error) {
+		_, err := dw.root.Lstat(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return err
+	}
+	if kept != "" {
+		log.Printf("keeping previous file '%s' as '%s'\n", filename, kept)
+		// linked rather than moved, so there's never a moment with nothing under the name
+		if err := dw.root.Link(filename, kept); err != nil {
+			return err
+		}
+	}
+	if err := dw.root.Rename(from, stored); err != nil {
+		return err
+	}
+	dw.stored, dw.kept = stored, kept
+	return dw.syncDir(".")
+}
+
+// closes the file without renaming it, and removes it, unless it's a partial upload
+func (dw *diskWriter) Abort() error {
+	var err error
+	if dw.f != nil {
+		log.Println("aborting file")
+		err = ignoreErrorFileAlreadyClosed(dw.f.Close())
+		dw.f = nil
+	}
+	if dw.tmp != "" {
+		if rmErr := dw.root.Remove(dw.tmp); rmErr != nil && err == nil {
+			err = rmErr
+		}
+		dw.tmp = ""
+	}
+	return err
+}
+
+// makes renames in a directory durable
+func (dw *diskWriter) syncDir(dir string) error {
+	d, err := dw.root.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (dw *diskWriter) removeTempFiles() error {
+	d, err := dw.root.Open(".")
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	entries, err := d.ReadDir(-1)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), tempPrefix) {
+			log.Printf("removing leftover temp file '%s'\n", dw.Locate(e.Name()))
+			if err := dw.root.Remove(e.Name()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (dw *diskWriter) Load(filename string) (io.ReadCloser, error) {
+	if err := dw.checkPath(filename); err != nil {
+		return nil, err
+	}
+	return dw.root.Open(filename)
+}
+
+func (dw *diskWriter) Locate(filename string) string {
+	return filepath.Join(dw.root.Name(), filename)
+}
+
+// the os.Root keeps symlinks from leading anywhere outside of the directory, but
+// there's no good reason for an upload to be a symlink to anywhere else in it either
+func (dw *diskWriter) checkPath(name string) error {
+	info, err := dw.root.Lstat(name)
+	if err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("%w: '%s' is a symlink", ErrUnsafeFileName, name)
 	}
 	return nil
 }
 
-func (dw *diskWriter) Load(filename string) ([]byte, error) {
-	return os.ReadFile(dw.filePath(filename))
+// partially received resumable uploads are kept in a hidden sub-directory until completed
+const partialDir = ".partial"
+
+func (dw *diskWriter) partialPath(uploadID string) string {
+	return filepath.Join(partialDir, uploadID)
+}
+
+func (dw *diskWriter) Offset(uploadID string) (int64, error) {
+	info, err := dw.root.Stat(dw.partialPath(uploadID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (dw *diskWriter) Resume(uploadID string, offset int64) error {
+	if err := dw.root.MkdirAll(partialDir, os.ModePerm); err != nil {
+		return err
+	}
+	fp := dw.partialPath(uploadID)
+	log.Printf("resuming file '%s' at offset %d\n", dw.Locate(fp), offset)
+	f, err := dw.root.OpenFile(fp, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	// drop anything past the offset, the client is going to send it again
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	dw.f, dw.name, dw.tmp = f, "", ""
+	return nil
+}
+
+func (dw *diskWriter) Commit(uploadID, filename string) error {
+	if err := dw.checkPath(filename); err != nil {
+		return err
+	}
+	return dw.store(dw.partialPath(uploadID), filename)
+}
+
+func (dw *diskWriter) Discard(uploadID string) error {
+	return dw.root.Remove(dw.partialPath(uploadID))
 }
 
-func (dw *diskWriter) filePath(filename string) string {
-	return filepath.Join(dw.writeDirPath, filename)
+func (dw *diskWriter) LoadPartial(uploadID string) (io.ReadCloser, error) {
+	return dw.root.Open(dw.partialPath(uploadID))
 }
 
 func ignoreErrorFileAlreadyClosed(err error) error {
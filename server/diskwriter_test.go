@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// names of everything in dir
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func checkFileContents(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("expected %s to contain %q, got %q", filepath.Base(path), want, got)
+	}
+}
+
+func TestDiskWriter_AtomicWrites(t *testing.T) {
+	dw := newTempDiskWriter(t)
+	dir := dw.root.Name()
+	path := filepath.Join(dir, "kiwi.txt")
+	write := func(w OpenWriteCloserLoader, data string) {
+		t.Helper()
+		if err := w.Open("kiwi.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := dw.NewWriter()
+	write(w, "kiwi and feijoa")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected nothing stored under the file name until closed, got: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContents(t, path, "kiwi and feijoa")
+
+	// an unfinished upload is kept out of sight, and leaves nothing behind once aborted
+	w = dw.NewWriter()
+	write(w, "tamarillo, and then...")
+	checkFileContents(t, path, "kiwi and feijoa")
+	if err := w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContents(t, path, "kiwi and feijoa")
+	if got := dirNames(t, dir); !slices.Equal(got, []string{"kiwi.txt"}) {
+		t.Errorf("expected only kiwi.txt to be left, got %v", got)
+	}
+
+	// a shorter upload replaces the file entirely
+	w = dw.NewWriter()
+	write(w, "kiwi")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContents(t, path, "kiwi")
+	// and aborting once closed changes nothing
+	if err := w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContents(t, path, "kiwi")
+	if got := dirNames(t, dir); !slices.Equal(got, []string{"kiwi.txt"}) {
+		t.Errorf("expected only kiwi.txt to be left, got %v", got)
+	}
+}
+
+func TestDiskWriter_RemovesLeftoverTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	// as if the server died mid-upload
+	for name, data := range map[string]string{tempPrefix + "KIWI": "half an upl", "kiwi.txt": "kiwi"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dw, err := newDiskWriter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dw.root.Close()
+	if got := dirNames(t, dir); !slices.Equal(got, []string{"kiwi.txt"}) {
+		t.Errorf("expected only kiwi.txt to be left, got %v", got)
+	}
+}
+
+func TestUploaderService_UploadFile_FailuresLeaveNoTrace(t *testing.T) {
+	dw := newTempDiskWriter(t)
+	dir := dw.root.Name()
+	uploadSvc := NewCustomUploader(dw)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	if _, err := sendDataInChunksToServer(t, client, `{"kiwi": 1}`, "kiwi.json", "application/json"); err != nil {
+		t.Fatal(err)
+	}
+	// a re-upload which doesn't check out keeps the file as it was
+	_, err := sendRequestsToServer(t, client, []*uploadpb.UploadRequest{
+		{FileName: "kiwi.json", MimeType: "application/json", Chunk: []byte(`{"kiwi": 2, "feijoa": 3}`), Sha256: make([]byte, 32)},
+	})
+	checkDetails(t, err, wantDetails{code: codes.DataLoss, reason: ReasonChecksumMismatch, metadata: map[string]string{"checksum": "sha256"}})
+	checkFileContents(t, filepath.Join(dir, "kiwi.json"), `{"kiwi": 1}`)
+	checkFileContents(t, filepath.Join(dir, "modified_kiwi.json"), `{"kiwi":1}`)
+
+	// nor is half a modified copy left behind when processing fails part way through
+	_, err = sendDataInChunksToServer(t, client, `{"fig": 2, "kiwi" 4}`, "fig.json", "application/json")
+	checkDetails(t, err, wantDetails{code: codes.InvalidArgument, resource: "fig.json", reason: ReasonJSONInvalid})
+	want := []string{"fig.json", "kiwi.json", "modified_kiwi.json"}
+	if got := dirNames(t, dir); !slices.Equal(got, want) {
+		t.Errorf("expected %v to be left, got %v", want, got)
+	}
+}
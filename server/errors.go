@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+/**
+ * Errors come back from the Uploader service with details attached, as per
+ * https://cloud.google.com/apis/design/errors#error_details, so clients can
+ * react to them without picking apart the message:
+ *  - BadRequest, naming the request field at fault
+ *  - ResourceInfo, naming the file (or job) the error is about
+ *  - ErrorInfo, with one of the reasons below, and metadata to go with it
+ */
+
+// domain of the ErrorInfo details
+const errorDomain = "fileupload.x-grpc"
+
+// ErrorInfo reasons, for clients to switch on
+const (
+	// the upload isn't valid JSON; metadata "offset" is the byte offset of the problem
+	ReasonJSONInvalid = "JSON_INVALID"
+	// the upload isn't valid data of its type, for types other than JSON (see ReasonJSONInvalid)
+	ReasonDataInvalid = "DATA_INVALID"
+	// a rule failed on the upload; metadata "path" is where
+	ReasonRuleFailed = "RULE_FAILED"
+	// the upload couldn't be post-processed, for some other reason
+	ReasonProcessingFailed = "PROCESSING_FAILED"
+	// a chunk or the whole file didn't match its checksum; metadata "checksum" is which one
+	ReasonChecksumMismatch = "CHECKSUM_MISMATCH"
+	// the declared mime type didn't match the detected one; metadata "declared" and "detected"
+	ReasonMimeTypeMismatch = "MIME_TYPE_MISMATCH"
+	// another stream is busy with the same resumable upload
+	ReasonUploadInProgress = "UPLOAD_IN_PROGRESS"
+	// the file couldn't be read from or written to storage
+	ReasonStorageFailed = "STORAGE_FAILED"
+	// the file name is taken, and the upload's conflict policy says to leave it be
+	ReasonFileExists = "FILE_EXISTS"
+)
+
+// attaches details to a status error, or leaves it be if they can't be
+func withDetails(err error, details ...protoiface.MessageV1) error {
+	st, err2 := status.Convert(err).WithDetails(details...)
+	if err2 != nil {
+		return err
+	}
+	return st.Err()
+}
+
+func fieldViolation(field, description string) *errdetails.BadRequest {
+	return &errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{{
+		Field:       field,
+		Description: description,
+	}}}
+}
+
+func errorInfo(reason string, metadata map[string]string) *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain, Metadata: metadata}
+}
+
+func resourceInfo(resourceType, name, description string) *errdetails.ResourceInfo {
+	return &errdetails.ResourceInfo{ResourceType: resourceType, ResourceName: name, Description: description}
+}
+
+// for a stored file that couldn't be read or written, failing to do `what`; the cause is
+// only logged, as it's apt to say where on the server the file is
+func storageError(what, filename string, cause error) error {
+	log.Printf("failed to %s '%s': %s", what, filename, cause)
+	return withDetails(status.Errorf(codes.Internal, "failed to %s", what),
+		resourceInfo("file", filename, ""), errorInfo(ReasonStorageFailed, nil))
+}
+
+// for a file name already taken, when it's not to be replaced
+func fileExistsError(filename string) error {
+	return withDetails(status.Errorf(codes.AlreadyExists, "file '%s' already exists", filename),
+		resourceInfo("file", filename, ""), errorInfo(ReasonFileExists, nil))
+}
+
+// turns an error from post-processing into a status error, blaming the upload where it's at fault
+func processingError(filename string, err error) error {
+	file := resourceInfo("file", filename, "")
+	var jsonErr *InvalidJSONError
+	if errors.As(err, &jsonErr) {
+		return withDetails(status.Errorf(codes.InvalidArgument, "uploaded data is %s", jsonErr),
+			file, errorInfo(ReasonJSONInvalid, map[string]string{"offset": strconv.FormatInt(jsonErr.Offset, 10)}))
+	}
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) {
+		return withDetails(status.Errorf(codes.InvalidArgument, "failed to apply rules to uploaded data: %s", ruleErr),
+			file, errorInfo(ReasonRuleFailed, map[string]string{"path": ruleErr.Path.String()}))
+	}
+	if errors.Is(err, ErrInvalidData) {
+		return withDetails(status.Errorf(codes.InvalidArgument, "uploaded data is %s", err),
+			file, errorInfo(ReasonDataInvalid, nil))
+	}
+	// anything else is likely trouble with storage, see storageError
+	log.Printf("failed to perform modifications to '%s': %s", filename, err)
+	return withDetails(status.Errorf(codes.Internal, "failed to perform modifications to uploaded data"),
+		file, errorInfo(ReasonProcessingFailed, nil))
+}
+
+// ErrInvalidData is wrapped by errors for uploads which aren't valid data of their type,
+// reading e.g. "not valid yaml data: ..." (JSON has InvalidJSONError, with more to say)
+var ErrInvalidData = errors.New("not valid")
+
+// RuleError is a rule failing on the value at Path
+type RuleError struct {
+	Path Path
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("at '%s': %s", e.Path, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+func ruleFailed(path Path, err error) error {
+	// paths get reused as the transformation moves on
+	return &RuleError{Path: append(Path{}, path...), Err: err}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"syscall"
+	"testing"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// where on the server a file is, which clients have no business knowing
+const serverPath = "/srv/received_files/a.txt"
+
+var errDiskFull = &fs.PathError{Op: "write", Path: serverPath, Err: syscall.ENOSPC}
+
+// a writer which fails on `failOn`: "open", "write" or "load"
+type failingWriter struct {
+	*bufwc
+	failOn string
+}
+
+func (f *failingWriter) NewWriter() OpenWriteCloserLoader {
+	return &failingWriter{bufwc: f.bufwc.NewWriter().(*bufwc), failOn: f.failOn}
+}
+
+func (f *failingWriter) Open(key string) error {
+	if f.failOn == "open" {
+		return errDiskFull
+	}
+	return f.bufwc.Open(key)
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.failOn == "write" {
+		return 0, errDiskFull
+	}
+	return f.bufwc.Write(p)
+}
+
+func (f *failingWriter) Load(key string) (io.ReadCloser, error) {
+	if f.failOn == "load" {
+		return nil, errDiskFull
+	}
+	return f.bufwc.Load(key)
+}
+
+// what's expected of an error, and the details that come with it
+type wantDetails struct {
+	code     codes.Code
+	field    string            // of a BadRequest field violation
+	resource string            // name from ResourceInfo
+	reason   string            // from ErrorInfo
+	metadata map[string]string // included in the ErrorInfo
+}
+
+func checkDetails(t *testing.T, err error, want wantDetails) {
+	t.Helper()
+	st := status.Convert(err)
+	if st.Code() != want.code {
+		t.Fatalf("expected %s, got: %v", want.code, err)
+	}
+	if strings.Contains(st.Message(), serverPath) {
+		t.Errorf("expected the server's file path to be kept from the client, got: %v", err)
+	}
+	var got wantDetails
+	got.code = st.Code()
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.BadRequest:
+			got.field = d.GetFieldViolations()[0].GetField()
+		case *errdetails.ResourceInfo:
+			got.resource = d.GetResourceName()
+			if strings.Contains(d.GetDescription(), serverPath) {
+				t.Errorf("expected the server's file path to be kept from the client, got %v", d)
+			}
+		case *errdetails.ErrorInfo:
+			if d.GetDomain() != errorDomain {
+				t.Errorf("unexpected ErrorInfo domain '%s'", d.GetDomain())
+			}
+			got.reason = d.GetReason()
+			for k, v := range want.metadata {
+				if d.GetMetadata()[k] != v {
+					t.Errorf("expected ErrorInfo metadata %s=%s, got %v", k, v, d.GetMetadata())
+				}
+			}
+		default:
+			t.Errorf("unexpected detail %T", d)
+		}
+	}
+	if got.field != want.field || got.resource != want.resource || got.reason != want.reason {
+		t.Errorf("expected details %+v, got %+v (%v)", want, got, err)
+	}
+}
+
+func TestUploaderService_UploadFile_ErrorDetails(t *testing.T) {
+	cases := []struct {
+		testName string
+		failOn   string
+		policy   MimePolicy
+		reqs     []*uploadpb.UploadRequest
+		want     wantDetails
+	}{
+		{"missing file name", "", TrustDeclared, []*uploadpb.UploadRequest{{Chunk: []byte("{}")}},
+			wantDetails{code: codes.InvalidArgument, field: "file_name"}},
+		{"invalid transform spec", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.json", Chunk: []byte("{}"), TransformSpec: `{"rules": [{"rule": "nope"}]}`}},
+			wantDetails{code: codes.InvalidArgument, field: "transform_spec"}},
+		{"mime type mismatch", "", RejectMismatch, []*uploadpb.UploadRequest{{FileName: "a.png", MimeType: "image/png", Chunk: []byte(`{"fig": 2}`)}},
+			wantDetails{code: codes.InvalidArgument, field: "mime_type", reason: ReasonMimeTypeMismatch, metadata: map[string]string{"declared": "image/png", "detected": "application/json"}}},
+		{"chunk checksum mismatch", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.txt", Chunk: []byte("abc"), Crc32C: new(uint32)}},
+			wantDetails{code: codes.DataLoss, reason: ReasonChecksumMismatch, metadata: map[string]string{"checksum": "crc32c", "offset": "0"}}},
+		{"file digest mismatch", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.txt", Chunk: []byte("abc"), Sha256: make([]byte, 32)}},
+			wantDetails{code: codes.DataLoss, reason: ReasonChecksumMismatch, metadata: map[string]string{"checksum": "sha256", "expected": strings.Repeat("0", 64)}}},
+		{"truncated file digest", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.txt", Chunk: []byte("abc"), Sha256: []byte{1, 2}}},
+			wantDetails{code: codes.InvalidArgument, field: "sha256"}},
+		{"invalid json", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.json", MimeType: "application/json", Chunk: []byte(`{"fig": 2, "kiwi" 4}`)}},
+			wantDetails{code: codes.InvalidArgument, resource: "a.json", reason: ReasonJSONInvalid, metadata: map[string]string{"offset": "19"}}},
+		{"rule failure", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.json", MimeType: "application/json", Chunk: []byte(`{"kiwi": [2000000000]}`),
+			TransformSpec: `{"rules": [{"rule": "scale-even-ints", "limit": "int32"}]}`}},
+			wantDetails{code: codes.InvalidArgument, resource: "a.json", reason: ReasonRuleFailed, metadata: map[string]string{"path": "kiwi.0"}}},
+		{"invalid yaml", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.yaml", MimeType: "application/yaml", Chunk: []byte("fig: [2\n")}},
+			wantDetails{code: codes.InvalidArgument, resource: "a.yaml", reason: ReasonDataInvalid}},
+		{"invalid toml", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.toml", MimeType: "application/toml", Chunk: []byte("fig = \n")}},
+			wantDetails{code: codes.InvalidArgument, resource: "a.toml", reason: ReasonDataInvalid}},
+		{"failed to open file", "open", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.txt", Chunk: []byte("abc")}},
+			wantDetails{code: codes.Internal, resource: "a.txt", reason: ReasonStorageFailed}},
+		{"failed to write file", "write", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.txt", Chunk: []byte("abc")}},
+			wantDetails{code: codes.Internal, resource: "a.txt", reason: ReasonStorageFailed}},
+		{"invalid upload id", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.txt", Chunk: []byte("abc"), UploadId: "../a"}},
+			wantDetails{code: codes.InvalidArgument, field: "upload_id"}},
+		{"offset past received bytes", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.txt", Chunk: []byte("abc"), UploadId: "a", Offset: 10}},
+			wantDetails{code: codes.OutOfRange, field: "offset"}},
+		{"upload in progress", "", TrustDeclared, []*uploadpb.UploadRequest{{FileName: "a.txt", Chunk: []byte("abc"), UploadId: "busy"}},
+			wantDetails{code: codes.Aborted, resource: "busy", reason: ReasonUploadInProgress}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			uploadSvc := NewCustomUploader(&failingWriter{bufwc: NewBufferWriter(), failOn: tt.failOn}, WithMimePolicy(tt.policy))
+			// as if another stream had it
+			uploadSvc.inProgress.Store("busy", struct{}{})
+			conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+				uploadpb.RegisterUploaderServer(srv, uploadSvc)
+			})
+			client := uploadpb.NewUploaderClient(conn)
+
+			_, err := sendRequestsToServer(t, client, tt.reqs)
+			checkDetails(t, err, tt.want)
+		})
+	}
+}
+
+func TestUploaderService_DownloadFile_ErrorDetails(t *testing.T) {
+	cases := []struct {
+		testName string
+		failOn   string
+		fileName string
+		want     wantDetails
+	}{
+		{"missing file name", "", "", wantDetails{code: codes.InvalidArgument, field: "file_name"}},
+		{"no such file", "", "nope.txt", wantDetails{code: codes.NotFound, resource: "nope.txt"}},
+		{"failed to load file", "load", "a.txt", wantDetails{code: codes.Internal, resource: "a.txt", reason: ReasonStorageFailed}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			uploadSvc := NewCustomUploader(&failingWriter{bufwc: NewBufferWriter(), failOn: tt.failOn})
+			conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+				uploadpb.RegisterUploaderServer(srv, uploadSvc)
+			})
+			client := uploadpb.NewUploaderClient(conn)
+
+			_, err := receiveDataFromServer(t, client, tt.fileName, false)
+			checkDetails(t, err, tt.want)
+		})
+	}
+}
+
+func TestUploaderService_GetJobStatus_ErrorDetails(t *testing.T) {
+	client := newJobsTestClient(t, NewBufferWriter(), newMemJobStore())
+	const unknown = "0123456789abcdef0123456789abcdef"
+	_, err := client.GetJobStatus(context.Background(), &uploadpb.JobStatusRequest{JobId: unknown})
+	checkDetails(t, err, wantDetails{code: codes.NotFound, resource: unknown})
+	_, err = client.GetJobStatus(context.Background(), &uploadpb.JobStatusRequest{JobId: "nope"})
+	checkDetails(t, err, wantDetails{code: codes.InvalidArgument, field: "job_id"})
+}
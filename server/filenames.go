@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrUnsafeFileName is a file name which could lead somewhere other than
+// a plain file of its own in the directory where uploads are stored
+var ErrUnsafeFileName = errors.New("unsafe file name")
+
+// longest file name accepted, leaving room for the `modified_` prefix within the usual limit of 255 bytes
+const maxFileNameLen = 255 - len("modified_")
+
+// names with special meanings to Windows, whatever the extension
+var reservedDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// names the server keeps for itself in the upload directory
+var reservedFileNames = map[string]bool{
+	partialDir:  true,
+	jobsDirName: true,
+}
+
+/**
+ * checkFileName makes sure a client-supplied file name is just that: the name of a file,
+ * with no path to it. Whatever the storage, file names are never trusted to stay put
+ * where they're joined onto a directory:
+ *  - no path separators (either kind) or drive letters, so no absolute paths or `..`
+ *  - no NUL bytes, control characters or invalid UTF-8
+ *  - not `.` or `..`, nor a name reserved by the server (`.partial`, `.tmp-*`...) or Windows (`CON`, `nul.txt`...)
+ *  - no trailing dot or space, which Windows would quietly drop
+ */
+func checkFileName(name string) error {
+	unsafe := func(reason string) error {
+		return fmt.Errorf("%w: %s", ErrUnsafeFileName, reason)
+	}
+	switch {
+	case name == "." || name == "..":
+		return unsafe("not a file name")
+	case len(name) > maxFileNameLen:
+		return unsafe(fmt.Sprintf("longer than %d bytes", maxFileNameLen))
+	case !utf8.ValidString(name):
+		return unsafe("not valid UTF-8")
+	case strings.ContainsAny(name, `/\`):
+		return unsafe("contains a path separator")
+	case strings.ContainsRune(name, ':'):
+		// drive letters and NTFS alternate data streams
+		return unsafe("contains a colon")
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return unsafe("contains a control character")
+	case strings.HasSuffix(name, ".") || strings.HasSuffix(name, " "):
+		return unsafe("ends with a dot or space")
+	case reservedFileNames[name] || strings.HasPrefix(name, tempPrefix):
+		return unsafe("reserved for the server")
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if reservedDeviceNames[strings.ToUpper(strings.TrimSpace(base))] {
+		return unsafe("reserved device name")
+	}
+	return nil
+}
+
+// the suffix of the name a file is kept under by KeepVersions, e.g. `kiwi.json.~1~`
+var keptSuffix = regexp.MustCompile(`\.~[0-9]+~$`)
+
+// checkUploadName is checkFileName for a file being uploaded, which also can't take a name
+// the server gives files of its own making: a `modified_` copy, or a version kept by
+// KeepVersions; they can still be downloaded by name, they just can't be replaced by an upload
+func checkUploadName(name string) error {
+	if err := checkFileName(name); err != nil {
+		return err
+	}
+	if strings.HasPrefix(name, modifiedName("")) || keptSuffix.MatchString(name) {
+		return fmt.Errorf("%w: reserved for copies made by the server", ErrUnsafeFileName)
+	}
+	return nil
+}
+
+// the error for a file name which isn't safe to use
+func fileNameError(name string, err error) error {
+	return withDetails(status.Errorf(codes.InvalidArgument, "invalid file_name %q: %s", name, err), fieldViolation("file_name", err.Error()))
+}
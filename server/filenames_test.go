@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var hostileFileNames = []struct {
+	testName string
+	name     string
+}{
+	{"parent directory", ".."},
+	{"current directory", "."},
+	{"traversal", "../../etc/cron.d/x"},
+	{"traversal after a directory", "fruit/../../x"},
+	{"windows traversal", `..\..\Windows\win.ini`},
+	{"absolute path", "/etc/passwd"},
+	{"windows absolute path", `C:\Windows\win.ini`},
+	{"drive relative", "C:x"},
+	{"unc path", `\\server\share\x`},
+	{"subdirectory", "fruit/kiwi.json"},
+	{"nul byte", "kiwi.json\x00.txt"},
+	{"control character", "kiwi\n.json"},
+	{"invalid utf-8", "kiwi\xff.json"},
+	{"reserved device", "CON"},
+	{"reserved device with extension", "nul.txt"},
+	{"reserved device in lowercase", "com1.json"},
+	{"trailing dot", "kiwi.json."},
+	{"trailing space", "kiwi.json "},
+	{"alternate data stream", "kiwi.json:hidden"},
+	{"partial uploads", partialDir},
+	{"jobs", jobsDirName},
+	{"temp file", tempPrefix + "KIWI"},
+	{"too long", strings.Repeat("a", maxFileNameLen+1)},
+}
+
+func TestCheckFileName(t *testing.T) {
+	for _, tt := range hostileFileNames {
+		t.Run(tt.testName, func(t *testing.T) {
+			if err := checkFileName(tt.name); !errors.Is(err, ErrUnsafeFileName) {
+				t.Errorf("expected %q to be rejected, got: %v", tt.name, err)
+			}
+		})
+	}
+	for _, name := range []string{
+		"kiwi.json",
+		"modified_kiwi.json",
+		"Fruit Salad (2).csv",
+		".env",
+		"..kiwi",
+		"kiwi..json",
+		"feijoa–tamarillo.yaml",
+		"CONSOLE.txt",
+		strings.Repeat("a", maxFileNameLen),
+	} {
+		if err := checkFileName(name); err != nil {
+			t.Errorf("expected %q to be fine, got: %v", name, err)
+		}
+	}
+}
+
+func TestCheckUploadName(t *testing.T) {
+	for _, name := range []string{"modified_kiwi.json", "kiwi.json.~1~", "kiwi.~12~", hostileFileNames[0].name} {
+		if err := checkUploadName(name); !errors.Is(err, ErrUnsafeFileName) {
+			t.Errorf("expected %q to be rejected, got: %v", name, err)
+		}
+	}
+	for _, name := range []string{"kiwi.json", "kiwi (1).json", "unmodified_kiwi.json", "kiwi.json~", "kiwi.~1", "kiwi.~a~", "kiwi.json.~1~.bak"} {
+		if err := checkUploadName(name); err != nil {
+			t.Errorf("expected %q to be fine, got: %v", name, err)
+		}
+	}
+}
+
+func TestUploaderService_UploadFile_HostileNames(t *testing.T) {
+	// stored within a directory of its own, to check nothing gets out of it
+	parent := t.TempDir()
+	dw, err := newDiskWriter(filepath.Join(parent, "received_files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dw.root.Close()
+	uploadSvc := NewCustomUploader(dw)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	for _, tt := range hostileFileNames {
+		t.Run(tt.testName, func(t *testing.T) {
+			if !utf8.ValidString(tt.name) || strings.TrimSpace(tt.name) != tt.name {
+				// never makes it to the server as is: won't be marshalled, or gets trimmed
+				t.Skip()
+			}
+			_, err := sendRequestsToServer(t, client, []*uploadpb.UploadRequest{{FileName: tt.name, Chunk: []byte("pwned")}})
+			checkDetails(t, err, wantDetails{code: codes.InvalidArgument, field: "file_name"})
+			_, err = receiveDataFromServer(t, client, tt.name, false)
+			checkDetails(t, err, wantDetails{code: codes.InvalidArgument, field: "file_name"})
+		})
+	}
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected nothing but the upload directory, found %v", entries)
+	}
+}
+
+func TestUploaderService_UploadFile_Symlinks(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "crontab")
+	if err := os.WriteFile(outside, []byte("untouched"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	dw, err := newDiskWriter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dw.root.Close()
+	// links to outside of the upload directory, and to another file within it
+	if err := os.Symlink(outside, filepath.Join(dir, "escape.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kiwi.txt"), []byte("kiwi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("kiwi.txt", filepath.Join(dir, "alias.txt")); err != nil {
+		t.Fatal(err)
+	}
+	uploadSvc := NewCustomUploader(dw)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	for _, name := range []string{"escape.txt", "alias.txt"} {
+		_, err := sendDataInChunksToServer(t, client, "pwned", name, "text/plain")
+		checkDetails(t, err, wantDetails{code: codes.InvalidArgument, field: "file_name"})
+		_, err = receiveDataFromServer(t, client, name, false)
+		checkDetails(t, err, wantDetails{code: codes.InvalidArgument, field: "file_name"})
+	}
+	for path, want := range map[string]string{outside: "untouched", filepath.Join(dir, "kiwi.txt"): "kiwi"} {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("expected %s to be left as %q, got %q", path, want, got)
+		}
+	}
+	// and even if a symlink were to get past the check, the os.Root won't follow it out
+	if _, err := dw.root.Open("escape.txt"); err == nil {
+		t.Errorf("expected opening a symlink out of the upload directory to fail")
+	}
+}
@@ -0,0 +1,606 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/**
+ * Post-processing a big upload can take a while, and there's no reason for the client
+ * to be kept waiting on it: with a job queue, UploadFile responds as soon as the upload
+ * is stored, and the modified copy gets written in the background by a bounded pool of
+ * workers. Clients follow along with GetJobStatus.
+ *
+ * Every change to a job is saved to a JobStore before anything else happens, so a job
+ * survives the server being restarted: anything queued or running at the time is picked
+ * up again by the next jobQueue on the same store. Failed attempts are retried a few
+ * times, with a growing delay in between, before the job is failed for good; unless it's
+ * the upload itself that's the problem (e.g. it's not valid JSON), which no retry can fix.
+ */
+
+// JobState is where a Job has got to
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+var jobStates = map[JobState]uploadpb.JobState{
+	JobQueued:    uploadpb.JobState_JOB_STATE_QUEUED,
+	JobRunning:   uploadpb.JobState_JOB_STATE_RUNNING,
+	JobSucceeded: uploadpb.JobState_JOB_STATE_SUCCEEDED,
+	JobFailed:    uploadpb.JobState_JOB_STATE_FAILED,
+}
+
+// Job is the post-processing of a single stored upload, as persisted by a JobStore
+type Job struct {
+	ID          string `json:"id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	// what the upload looked like, which may say to process it as JSON regardless
+	DetectedContentType string `json:"detected_content_type,omitempty"`
+	// of the upload, so it's that which is processed, not whatever's been uploaded since
+	Sha256 string `json:"sha256,omitempty"`
+	// as sent by the client, since the parsed rules can't be saved
+	TransformSpec string    `json:"transform_spec,omitempty"`
+	State         JobState  `json:"state"`
+	Attempts      int       `json:"attempts"`
+	Error         string    `json:"error,omitempty"`
+	LineErrors    []jobLine `json:"line_errors,omitempty"`
+	FailedLines   int       `json:"failed_lines,omitempty"`
+	// what the rules did, once the job has succeeded
+	KeysRemoved   int           `json:"keys_removed,omitempty"`
+	IntsRewritten int           `json:"ints_rewritten,omitempty"`
+	IntsSaturated int           `json:"ints_saturated,omitempty"`
+	Elapsed       time.Duration `json:"elapsed,omitempty"`
+	Created       time.Time     `json:"created"`
+	Updated       time.Time     `json:"updated"`
+}
+
+type jobLine struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// JobStore keeps track of jobs, so they outlive the server process
+type JobStore interface {
+	// Save creates or replaces the job
+	Save(job *Job) error
+	// Load returns the job with the given ID, or an error wrapping fs.ErrNotExist
+	Load(id string) (*Job, error)
+	// List returns every job in the store, in no particular order
+	List() ([]*Job, error)
+}
+
+// job IDs end up in file paths too
+var jobIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// defaults for how hard a job is tried before giving up on it
+const (
+	defaultJobAttempts   = 3
+	defaultJobRetryDelay = time.Second
+)
+
+type jobQueue struct {
+	store   JobStore
+	writers WriterFactory
+	// attempts per job, and the delay before the first retry (doubling after that)
+	maxAttempts int
+	retryDelay  time.Duration
+
+	mu      sync.Mutex
+	ready   *sync.Cond
+	pending []string // IDs of jobs waiting for a worker
+	busy    int      // jobs being run, or waiting to be retried
+	idle    *sync.Cond
+	// files with jobs running (or waiting to), which only run one at a time for the same file,
+	// so an earlier upload's job can't save over the modified copy from a later one's
+	files   map[string]*fileLock
+	stopped bool
+	workers sync.WaitGroup
+}
+
+// JobQueueOption configures optional behaviour of the job queue, before any jobs are run
+type JobQueueOption func(*jobQueue)
+
+// WithJobRetries sets how many times a job is attempted, and the delay before the first retry
+// (doubling after that), instead of defaultJobAttempts and defaultJobRetryDelay
+func WithJobRetries(attempts int, delay time.Duration) JobQueueOption {
+	return func(q *jobQueue) {
+		q.maxAttempts, q.retryDelay = attempts, delay
+	}
+}
+
+// starts `workers` workers on jobs from `store`, beginning with anything left unfinished in it
+func newJobQueue(writers WriterFactory, store JobStore, workers int, opts ...JobQueueOption) *jobQueue {
+	q := &jobQueue{
+		store:       store,
+		writers:     writers,
+		maxAttempts: defaultJobAttempts,
+		retryDelay:  defaultJobRetryDelay,
+		files:       make(map[string]*fileLock),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.ready = sync.NewCond(&q.mu)
+	q.idle = sync.NewCond(&q.mu)
+	q.recover()
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// requeues the jobs which were queued or running when the last server stopped
+func (q *jobQueue) recover() {
+	jobs, err := q.store.List()
+	if err != nil {
+		log.Printf("failed to list jobs to recover: %s", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.State != JobQueued && job.State != JobRunning {
+			continue
+		}
+		if job.State == JobRunning {
+			// it never got to finish
+			job.State = JobQueued
+			if err := q.save(job); err != nil {
+				log.Printf("failed to requeue job '%s': %s", job.ID, err)
+				continue
+			}
+		}
+		log.Printf("recovered job '%s' for '%s'", job.ID, job.FileName)
+		q.pending = append(q.pending, job.ID)
+	}
+}
+
+// queues post-processing of a stored upload of `contentType` (looking like `detected`) with
+// the given digest, returning the new job's ID
+func (q *jobQueue) enqueue(filename, contentType, detected, transformSpec string, digest []byte) (string, error) {
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+	job := &Job{
+		ID:                  id,
+		FileName:            filename,
+		ContentType:         contentType,
+		DetectedContentType: detected,
+		Sha256:              hex.EncodeToString(digest),
+		TransformSpec:       transformSpec,
+		State:               JobQueued,
+		Created:             time.Now().UTC(),
+	}
+	if err := q.save(job); err != nil {
+		return "", err
+	}
+	q.push(id)
+	return id, nil
+}
+
+func (q *jobQueue) push(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stopped {
+		// still queued in the store, for next time
+		return
+	}
+	q.pending = append(q.pending, id)
+	q.ready.Signal()
+}
+
+// puts a job back in the queue once it's waited long enough to be retried
+func (q *jobQueue) retry(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.busy--
+	if q.stopped {
+		// still queued in the store, for next time
+		q.idle.Broadcast()
+		return
+	}
+	q.pending = append(q.pending, id)
+	q.ready.Signal()
+}
+
+func (q *jobQueue) save(job *Job) error {
+	job.Updated = time.Now().UTC()
+	return q.store.Save(job)
+}
+
+func (q *jobQueue) work() {
+	defer q.workers.Done()
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 && !q.stopped {
+			q.ready.Wait()
+		}
+		if q.stopped {
+			q.mu.Unlock()
+			return
+		}
+		id := q.pending[0]
+		q.pending = q.pending[1:]
+		q.busy++
+		q.mu.Unlock()
+
+		if err := q.run(id); err != nil {
+			log.Printf("job '%s': %s", id, err)
+		}
+		q.mu.Lock()
+		q.busy--
+		q.idle.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+// makes an attempt at a job, deciding what happens next if it fails;
+// errors are from the store, rather than the job itself
+func (q *jobQueue) run(id string) error {
+	job, err := q.store.Load(id)
+	if err != nil {
+		return err
+	}
+	job.State = JobRunning
+	job.Attempts++
+	if err := q.save(job); err != nil {
+		return err
+	}
+	report, err := q.process(job)
+	if err != nil {
+		// reported back to the client, so only the upload's own problems are spelt out, see storageError
+		job.Error = "failed to perform modifications to uploaded data"
+		if failsForGood(err) {
+			job.Error = err.Error()
+			log.Printf("job '%s' failed: %s", id, err)
+			job.State = JobFailed
+			return q.save(job)
+		}
+		if job.Attempts >= q.maxAttempts {
+			log.Printf("job '%s' failed after %d attempts: %s", id, job.Attempts, err)
+			job.State = JobFailed
+			return q.save(job)
+		}
+		log.Printf("job '%s' attempt %d failed, retrying: %s", id, job.Attempts, err)
+		job.State = JobQueued
+		if err := q.save(job); err != nil {
+			return err
+		}
+		q.mu.Lock()
+		q.busy++ // until it's back in the queue
+		q.mu.Unlock()
+		time.AfterFunc(q.retryDelay<<(job.Attempts-1), func() { q.retry(id) })
+		return nil
+	}
+	job.State = JobSucceeded
+	job.Error = ""
+	job.LineErrors = nil
+	for _, le := range report.LineErrors {
+		job.LineErrors = append(job.LineErrors, jobLine{Line: le.Line, Error: le.Err.Error()})
+	}
+	job.FailedLines = report.FailedLines
+	job.KeysRemoved, job.IntsRewritten, job.IntsSaturated = report.KeysRemoved, report.IntsRewritten, report.IntsSaturated
+	job.Elapsed = report.Elapsed
+	return q.save(job)
+}
+
+func (q *jobQueue) process(job *Job) (*ProcessReport, error) {
+	var spec *TransformSpec
+	if job.TransformSpec != "" {
+		var err error
+		if spec, err = ParseTransformSpec(job.TransformSpec); err != nil {
+			return nil, fmt.Errorf("%w: %w", errJobSpec, err)
+		}
+	}
+	process, err := processorFor(job.ContentType, job.DetectedContentType, spec)
+	if err != nil {
+		return nil, err
+	}
+	if process == nil {
+		// nothing to do (jobs are only queued when there is, though)
+		return &ProcessReport{}, nil
+	}
+	// so a job for an earlier upload either finishes before the job for a later one starts,
+	// or finds its upload has been replaced
+	defer q.lockFile(job.FileName)()
+	w := q.writers.NewWriter()
+	defer w.Close()
+	r, err := openUpload(w, job)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return runProcessor(process, job.FileName, &pinnedLoader{OpenWriteCloserLoader: w, filename: job.FileName, r: r}, spec)
+}
+
+// a lock on a file name, for as long as any job needs it
+type fileLock struct {
+	sync.Mutex
+	jobs int // running or waiting, guarded by jobQueue.mu
+}
+
+// waits until no other job is running for the file, returning the func to call when done
+func (q *jobQueue) lockFile(filename string) (unlock func()) {
+	q.mu.Lock()
+	l := q.files[filename]
+	if l == nil {
+		l = &fileLock{}
+		q.files[filename] = l
+	}
+	l.jobs++
+	q.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		q.mu.Lock()
+		if l.jobs--; l.jobs == 0 {
+			delete(q.files, filename)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// errJobSpec is wrapped by the error for a job whose spec doesn't parse
+var errJobSpec = errors.New("transform_spec")
+
+// whether a job failing with err would only fail the same way if it were tried again:
+// because of the upload (or the spec it came with), rather than trouble with storage
+func failsForGood(err error) bool {
+	var jsonErr *InvalidJSONError
+	var ruleErr *RuleError
+	return errors.As(err, &jsonErr) || errors.As(err, &ruleErr) || errors.Is(err, ErrInvalidData) ||
+		errors.Is(err, errJobSpec) || errors.Is(err, ErrNoProcessor) || errors.Is(err, errUploadReplaced)
+}
+
+// errUploadReplaced is the error for a job whose upload was replaced before the job got to it,
+// which is left to the job for the upload that replaced it
+var errUploadReplaced = errors.New("replaced by a later upload before it could be processed")
+
+// opens the upload a job is for, checking it's still the one that was uploaded
+func openUpload(w OpenWriteCloserLoader, job *Job) (io.ReadCloser, error) {
+	r, err := w.Load(job.FileName)
+	if err != nil || job.Sha256 == "" {
+		// jobs queued before digests were recorded go by whatever's there
+		return r, err
+	}
+	digest := sha256.New()
+	if rs, ok := r.(io.ReadSeeker); ok {
+		// e.g. an open file, which stays the same even if another is renamed into its place
+		if _, err = io.Copy(digest, rs); err == nil {
+			_, err = rs.Seek(0, io.SeekStart)
+		}
+	} else {
+		// read it all in, so it can't change between being checked and processed
+		var data []byte
+		data, err = io.ReadAll(r)
+		r.Close()
+		digest.Write(data)
+		r = io.NopCloser(bytes.NewReader(data))
+	}
+	if err == nil && hex.EncodeToString(digest.Sum(nil)) != job.Sha256 {
+		err = fmt.Errorf("'%s' was %w", job.FileName, errUploadReplaced)
+	}
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// pinnedLoader is a writer session which loads the upload a job is for from the reader
+// already opened (and checked) by openUpload, rather than whatever's stored by then
+type pinnedLoader struct {
+	OpenWriteCloserLoader
+	filename string
+	r        io.ReadCloser
+}
+
+func (l *pinnedLoader) Load(filename string) (io.ReadCloser, error) {
+	if filename != l.filename || l.r == nil {
+		return l.OpenWriteCloserLoader.Load(filename)
+	}
+	r := l.r
+	l.r = nil
+	// closed by process
+	return io.NopCloser(r), nil
+}
+
+// waits up to `timeout` for every job to be done with, whether queued, running or waiting
+// to be retried, then stops the queue; anything left by then stays queued in the store
+func (q *jobQueue) drain(timeout time.Duration) {
+	expired := false
+	timer := time.AfterFunc(timeout, func() {
+		q.mu.Lock()
+		expired = true
+		q.idle.Broadcast()
+		q.mu.Unlock()
+	})
+	defer timer.Stop()
+	q.mu.Lock()
+	for (len(q.pending) > 0 || q.busy > 0) && !expired && !q.stopped {
+		q.idle.Wait()
+	}
+	q.mu.Unlock()
+	q.stop()
+}
+
+// stops the workers once they've finished whatever they're in the middle of;
+// anything still queued stays that way in the store
+func (q *jobQueue) stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.ready.Broadcast()
+	q.idle.Broadcast()
+	q.mu.Unlock()
+	q.workers.Wait()
+}
+
+// WithJobQueue moves post-processing off the upload stream and onto `workers` background
+// workers, with jobs kept in `store`; without it, uploads are processed before UploadFile responds
+func WithJobQueue(store JobStore, workers int, opts ...JobQueueOption) UploaderOption {
+	return func(u *Uploader) {
+		u.jobs = newJobQueue(u.io_thingee, store, workers, opts...)
+	}
+}
+
+// Drain is Stop, except queued post-processing jobs get up to `timeout` to be run first
+func (u *Uploader) Drain(timeout time.Duration) {
+	if u.jobs != nil {
+		u.jobs.drain(timeout)
+	}
+	u.Stop()
+}
+
+// Stop waits for any running post-processing jobs to finish, and starts no more,
+// and stops collecting garbage
+func (u *Uploader) Stop() {
+	if u.jobs != nil {
+		u.jobs.stop()
+	}
+	if u.stopGC != nil {
+		u.stopGC()
+	}
+}
+
+func (u *Uploader) GetJobStatus(ctx context.Context, req *uploadpb.JobStatusRequest) (*uploadpb.JobStatusResponse, error) {
+	if u.jobs == nil {
+		return nil, status.Errorf(codes.Unimplemented, "uploads are not post-processed in the background")
+	}
+	id := req.GetJobId()
+	if !jobIDPattern.MatchString(id) {
+		return nil, withDetails(status.Errorf(codes.InvalidArgument, "invalid job_id '%s'", id), fieldViolation("job_id", "must be a job ID from an UploadResponse"))
+	}
+	job, err := u.jobs.store.Load(id)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, withDetails(status.Errorf(codes.NotFound, "no such job '%s'", id), resourceInfo("job", id, "not found"))
+	}
+	if err != nil {
+		log.Printf("failed to load job '%s': %s", id, err)
+		return nil, withDetails(status.Errorf(codes.Internal, "failed to load job"), resourceInfo("job", id, ""))
+	}
+	resp := &uploadpb.JobStatusResponse{
+		JobId:       job.ID,
+		FileName:    job.FileName,
+		State:       jobStates[job.State],
+		Attempts:    uint32(job.Attempts),
+		Error:       job.Error,
+		FailedLines: uint64(job.FailedLines),
+	}
+	for _, le := range job.LineErrors {
+		resp.LineErrors = append(resp.LineErrors, &uploadpb.LineError{Line: uint64(le.Line), Error: le.Error})
+	}
+	if job.State == JobSucceeded {
+		resp.Processing = &uploadpb.ProcessingReport{
+			Processed:        true,
+			ModifiedFileName: modifiedName(job.FileName),
+			KeysRemoved:      uint64(job.KeysRemoved),
+			IntsRewritten:    uint64(job.IntsRewritten),
+			IntsSaturated:    uint64(job.IntsSaturated),
+			ProcessingMicros: uint64(job.Elapsed.Microseconds()),
+		}
+	}
+	return resp, nil
+}
+
+// diskJobStore keeps each job as a JSON file in a directory
+type diskJobStore struct {
+	dir string
+}
+
+// Check interface conformity
+var _ JobStore = &diskJobStore{}
+
+func (s *diskJobStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *diskJobStore) Save(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	// write it out in full before it replaces the last version,
+	// so there's never a half-written job to be found after a crash
+	tmp, err := os.CreateTemp(s.dir, job.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(job.ID))
+}
+
+func (s *diskJobStore) Load(id string) (*Job, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, fmt.Errorf("corrupt job '%s': %w", id, err)
+	}
+	return job, nil
+}
+
+func (s *diskJobStore) List() ([]*Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !jobIDPattern.MatchString(id) {
+			continue
+		}
+		job, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
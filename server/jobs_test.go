@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// an uploader with a job queue on `store`, stopped once the test is done
+func newJobsTestClient(t *testing.T, buf *bufwc, store JobStore) uploadpb.UploaderClient {
+	uploadSvc := NewCustomUploader(buf, WithJobQueue(store, 2, WithJobRetries(defaultJobAttempts, time.Millisecond)))
+	t.Cleanup(uploadSvc.Stop)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	return uploadpb.NewUploaderClient(conn)
+}
+
+// polls the job until it's done, one way or another
+func waitForJob(t *testing.T, client uploadpb.UploaderClient, jobID string) *uploadpb.JobStatusResponse {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := client.GetJobStatus(context.Background(), &uploadpb.JobStatusRequest{JobId: jobID})
+		if err != nil {
+			t.Fatalf("client.GetJobStatus: %s", err)
+		}
+		switch resp.GetState() {
+		case uploadpb.JobState_JOB_STATE_SUCCEEDED, uploadpb.JobState_JOB_STATE_FAILED:
+			return resp
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job '%s' still %s", jobID, resp.GetState())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestUploaderService_UploadFile_Jobs(t *testing.T) {
+	cases := []struct {
+		testName     string
+		fileName     string
+		mimeType     string
+		data         string
+		want         string // modified copy
+		state        uploadpb.JobState
+		attempts     uint32
+		failedLines  uint64
+		errorMessage string
+	}{
+		{"json", "fruit.json", "application/json", `{"apple": 1, "fig": 2}`, `{"fig":2000}`, uploadpb.JobState_JOB_STATE_SUCCEEDED, 1, 0, ""},
+		{"ndjson with bad lines", "log.ndjson", "application/x-ndjson", "{\"fig\": 2}\n{\"fig\":\n", "{\"fig\":2000}\n", uploadpb.JobState_JOB_STATE_SUCCEEDED, 1, 1, ""},
+		{"invalid json", "broken.json", "application/json", `{"fig": 2`, "", uploadpb.JobState_JOB_STATE_FAILED, 1, 0, "not valid json data"},
+		{"invalid yaml", "broken.yaml", "application/yaml", "fig: [2", "", uploadpb.JobState_JOB_STATE_FAILED, 1, 0, "not valid yaml data"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			buf := NewBufferWriter()
+			client := newJobsTestClient(t, buf, newMemJobStore())
+
+			resp, err := sendDataInChunksToServer(t, client, tt.data, tt.fileName, tt.mimeType)
+			if err != nil {
+				// a failing job doesn't fail the upload
+				t.Fatalf("client.UploadFile: %s", err)
+			}
+			if resp.GetJobId() == "" {
+				t.Fatal("expected a job ID")
+			}
+			job := waitForJob(t, client, resp.GetJobId())
+			if job.GetState() != tt.state || job.GetAttempts() != tt.attempts {
+				t.Fatalf("expected job %s after %d attempts, got %s after %d: %s", tt.state, tt.attempts, job.GetState(), job.GetAttempts(), job.GetError())
+			}
+			if !strings.Contains(job.GetError(), tt.errorMessage) || (tt.errorMessage == "") != (job.GetError() == "") {
+				t.Errorf("expected error containing %q, got %q", tt.errorMessage, job.GetError())
+			}
+			if job.GetFileName() != tt.fileName || job.GetFailedLines() != tt.failedLines || len(job.GetLineErrors()) != int(tt.failedLines) {
+				t.Errorf("unexpected job status: %v", job)
+			}
+			if processed := job.GetProcessing().GetProcessed(); processed != (tt.state == uploadpb.JobState_JOB_STATE_SUCCEEDED) {
+				t.Errorf("expected processing report to say processed %t, got %v", !processed, job.GetProcessing())
+			}
+			if got := string(buf.m["modified_"+tt.fileName]); got != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUploaderService_UploadFile_JobsNotNeeded(t *testing.T) {
+	buf := NewBufferWriter()
+	client := newJobsTestClient(t, buf, newMemJobStore())
+
+	resp, err := sendDataInChunksToServer(t, client, "just some text", "notes.txt", "text/plain")
+	if err != nil {
+		t.Fatalf("client.UploadFile: %s", err)
+	}
+	if resp.GetJobId() != "" {
+		t.Errorf("expected no job for an upload with nothing to process, got '%s'", resp.GetJobId())
+	}
+}
+
+func TestUploaderService_Jobs_Recover(t *testing.T) {
+	buf := NewBufferWriter()
+	buf.m["queued.json"] = []byte(`{"fig": 2}`)
+	buf.m["running.json"] = []byte(`{"kiwi": 4}`)
+	store := newMemJobStore()
+	// as left behind by a server that was stopped in the middle of things
+	jobs := []Job{
+		{ID: strings.Repeat("a", 32), FileName: "queued.json", ContentType: "application/json", State: JobQueued},
+		{ID: strings.Repeat("b", 32), FileName: "running.json", ContentType: "application/json", State: JobRunning, Attempts: 1},
+		{ID: strings.Repeat("c", 32), FileName: "done.json", ContentType: "application/json", State: JobSucceeded, Attempts: 1},
+	}
+	for i := range jobs {
+		store.Save(&jobs[i])
+	}
+
+	client := newJobsTestClient(t, buf, store)
+	for _, job := range jobs {
+		resp := waitForJob(t, client, job.ID)
+		if resp.GetState() != uploadpb.JobState_JOB_STATE_SUCCEEDED {
+			t.Errorf("expected job '%s' to succeed, got %s: %s", job.FileName, resp.GetState(), resp.GetError())
+		}
+	}
+	if got := string(buf.m["modified_queued.json"]); got != `{"fig":2000}` {
+		t.Errorf("unexpected modified copy of queued job: %s", got)
+	}
+	if got := string(buf.m["modified_running.json"]); got != `{"kiwi":4000}` {
+		t.Errorf("unexpected modified copy of running job: %s", got)
+	}
+	if _, ok := buf.m["modified_done.json"]; ok {
+		t.Errorf("expected finished job not to be run again")
+	}
+}
+
+// draining runs whatever's queued (retries included) before stopping, for as long as it's given
+func TestUploaderService_Jobs_Drain(t *testing.T) {
+	buf := NewBufferWriter()
+	buf.m["fig.json"] = []byte(`{"fig": 2}`)
+	store := newMemJobStore()
+	jobs := []Job{
+		{ID: strings.Repeat("a", 32), FileName: "fig.json", ContentType: "application/json", State: JobQueued},
+		{ID: strings.Repeat("b", 32), FileName: "missing.json", ContentType: "application/json", State: JobQueued},
+	}
+	for i := range jobs {
+		store.Save(&jobs[i])
+	}
+	NewCustomUploader(buf, WithJobQueue(store, 1, WithJobRetries(defaultJobAttempts, time.Millisecond))).Drain(5 * time.Second)
+	for _, want := range []struct {
+		id       string
+		state    JobState
+		attempts int
+	}{{jobs[0].ID, JobSucceeded, 1}, {jobs[1].ID, JobFailed, defaultJobAttempts}} {
+		job, err := store.Load(want.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State != want.state || job.Attempts != want.attempts {
+			t.Errorf("expected job for '%s' to be %s after %d attempts, got %s after %d",
+				job.FileName, want.state, want.attempts, job.State, job.Attempts)
+		}
+	}
+
+	// with no workers, nothing gets done, but draining still gives up in time
+	store = newMemJobStore()
+	store.Save(&Job{ID: strings.Repeat("c", 32), FileName: "fig.json", ContentType: "application/json", State: JobQueued})
+	start := time.Now()
+	NewCustomUploader(buf, WithJobQueue(store, 0)).Drain(10 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected draining to give up after 10ms, took %s", elapsed)
+	}
+	if job, err := store.Load(strings.Repeat("c", 32)); err != nil || job.State != JobQueued {
+		t.Errorf("expected the job to be left queued for next time, got %+v (%v)", job, err)
+	}
+}
+
+// a job processes the upload that queued it, not one that's replaced it since
+func TestUploaderService_Jobs_Replaced(t *testing.T) {
+	buf := NewBufferWriter()
+	buf.m["fruit.json"] = []byte(`{"kiwi": 4}`)
+	store := newMemJobStore()
+	jobs := []Job{
+		{ID: strings.Repeat("a", 32), FileName: "fruit.json", ContentType: "application/json", Sha256: digestOf(`{"fig": 2}`), State: JobQueued},
+		{ID: strings.Repeat("b", 32), FileName: "fruit.json", ContentType: "application/json", Sha256: digestOf(`{"kiwi": 4}`), State: JobQueued},
+	}
+	for i := range jobs {
+		store.Save(&jobs[i])
+	}
+	client := newJobsTestClient(t, buf, store)
+
+	resp := waitForJob(t, client, jobs[0].ID)
+	if resp.GetState() != uploadpb.JobState_JOB_STATE_FAILED || !strings.Contains(resp.GetError(), "replaced") || resp.GetAttempts() != 1 {
+		t.Errorf("expected the job for the replaced upload to fail without a retry, got %s after %d attempts: %s", resp.GetState(), resp.GetAttempts(), resp.GetError())
+	}
+	resp = waitForJob(t, client, jobs[1].ID)
+	if resp.GetState() != uploadpb.JobState_JOB_STATE_SUCCEEDED {
+		t.Errorf("expected the job for the latest upload to succeed, got %s: %s", resp.GetState(), resp.GetError())
+	}
+	// and only the latest upload's
+	if got := string(buf.m["modified_fruit.json"]); got != `{"kiwi":4000}` {
+		t.Errorf("unexpected modified copy: %s", got)
+	}
+}
+
+// trouble with storage might not last, so it's worth another go
+// jobs for the same file wait their turn, so a stale one can't save over a newer one's output
+func TestJobQueue_LockFile(t *testing.T) {
+	q := newJobQueue(NewBufferWriter(), newMemJobStore(), 0)
+	unlock := q.lockFile("kiwi.json")
+	q.lockFile("fig.json")()
+	locked, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		unlock := q.lockFile("kiwi.json")
+		close(locked)
+		unlock()
+		close(done)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("expected the second job for kiwi.json to wait for the first")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the second job for kiwi.json to run once the first was done")
+	}
+	<-done
+	q.stop()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.files) != 0 {
+		t.Errorf("expected no file locks left over, got %v", q.files)
+	}
+}
+
+func TestUploaderService_Jobs_Retries(t *testing.T) {
+	store := newMemJobStore()
+	job := &Job{ID: strings.Repeat("a", 32), FileName: "missing.json", ContentType: "application/json", State: JobQueued}
+	store.Save(job)
+	client := newJobsTestClient(t, NewBufferWriter(), store)
+
+	resp := waitForJob(t, client, job.ID)
+	if resp.GetState() != uploadpb.JobState_JOB_STATE_FAILED || resp.GetAttempts() != defaultJobAttempts {
+		t.Errorf("expected the job to fail after %d attempts, got %s after %d: %s", defaultJobAttempts, resp.GetState(), resp.GetAttempts(), resp.GetError())
+	}
+}
+
+func TestUploaderService_GetJobStatus_Errors(t *testing.T) {
+	client := newJobsTestClient(t, NewBufferWriter(), newMemJobStore())
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, NewCustomUploader(NewBufferWriter()))
+	})
+	syncClient := uploadpb.NewUploaderClient(conn)
+
+	cases := []struct {
+		testName string
+		client   uploadpb.UploaderClient
+		jobID    string
+		code     codes.Code
+	}{
+		{"unknown job", client, strings.Repeat("0", 32), codes.NotFound},
+		{"invalid job ID", client, "../../etc/passwd", codes.InvalidArgument},
+		{"no job queue", syncClient, strings.Repeat("0", 32), codes.Unimplemented},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := tt.client.GetJobStatus(context.Background(), &uploadpb.JobStatusRequest{JobId: tt.jobID})
+			if status.Code(err) != tt.code {
+				t.Errorf("expected %s, got: %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestDiskJobStore(t *testing.T) {
+	store := &diskJobStore{dir: t.TempDir()}
+	job := &Job{ID: strings.Repeat("d", 32), FileName: "fruit.json", State: JobQueued}
+	if err := store.Save(job); err != nil {
+		t.Fatal(err)
+	}
+	job.State = JobFailed
+	job.Error = "no good"
+	if err := store.Save(job); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Load(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.State != JobFailed || got.Error != "no good" || got.FileName != "fruit.json" {
+		t.Errorf("unexpected job loaded: %+v", got)
+	}
+	jobs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("expected just the one job (and no temp files), got %+v", jobs)
+	}
+}
+
+// memJobStore keeps jobs in memory, for testing
+type memJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+// Check interface conformity
+var _ JobStore = &memJobStore{}
+
+func newMemJobStore() *memJobStore {
+	return &memJobStore{jobs: make(map[string]Job)}
+}
+
+// jobs are stored by value, so callers never share one
+
+func (s *memJobStore) Save(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.ID] = *job
+	return nil
+}
+
+func (s *memJobStore) Load(id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("no such job '%s': %w", id, fs.ErrNotExist)
+	}
+	return &job, nil
+}
+
+func (s *memJobStore) List() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []*Job
+	for _, job := range s.jobs {
+		job := job
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
@@ -3,14 +3,29 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	uploadpb "github.com/benjamin-rood/x-grpc/proto"
 	"google.golang.org/grpc"
 )
 
 func main() {
+	versioned := flag.Bool("versioned", false, "keep every version of a file as it's replaced, rather than just the latest")
+	retain := flag.Int("retain", 0, "with -versioned, how many of the latest versions of a file to keep (0 keeps them all)")
+	dedupe := flag.Bool("dedupe", false, "store files by their contents, so the same contents are only ever stored once")
+	drain := flag.Duration("drain", 30*time.Second, "on SIGINT/SIGTERM, how long to give queued post-processing jobs before exiting")
+	flag.Parse()
+	if *versioned && *dedupe {
+		log.Fatal("-versioned and -dedupe can't be used together")
+	}
+
 	// initialise TCP listener with a random port unlikely to conflict
 	ln, err := net.Listen("tcp", ":59999")
 	if err != nil {
@@ -18,9 +33,30 @@ func main() {
 	}
 	defer ln.Close()
 
-	uploadService := DefaultUploader()
+	var uploadService *Uploader
+	switch {
+	case *versioned:
+		uploadService = DefaultVersionedUploader(*retain)
+	case *dedupe:
+		uploadService = DefaultDedupingUploader()
+	default:
+		uploadService = DefaultUploader()
+	}
 	grpcServer := grpc.NewServer()
 
 	uploadpb.RegisterUploaderServer(grpcServer, uploadService)
-	log.Fatal(grpcServer.Serve(ln))
+
+	// on SIGINT/SIGTERM, let uploads in progress finish, then give post-processing jobs up to
+	// -drain to finish too, before exiting (anything still queued is picked up next time)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("shutting down")
+		grpcServer.GracefulStop()
+	}()
+	if err := grpcServer.Serve(ln); err != nil {
+		log.Fatal(err)
+	}
+	uploadService.Drain(*drain)
 }
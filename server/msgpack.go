@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// ProcessMsgpack is ProcessJSON for MessagePack uploads, including a stream of values; see binary.go
+func ProcessMsgpack(filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error) {
+	err := processFile(filename, x, func(r io.Reader, w io.Writer) error {
+		return transformBinary(&msgpackFormat{dec: msgpack.NewDecoder(r)}, w, spec.rules(), spec.outputJSON())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write modified MessagePack data to file: %w", err)
+	}
+	return &ProcessReport{}, nil
+}
+
+type msgpackFormat struct {
+	dec   *msgpack.Decoder
+	depth int
+}
+
+func (f *msgpackFormat) next() (any, error) {
+	if _, err := f.dec.PeekCode(); err == io.EOF {
+		return nil, err
+	}
+	v, err := f.tree()
+	if err != nil {
+		return nil, fmt.Errorf("%w msgpack data: %w", ErrInvalidData, err)
+	}
+	return v, nil
+}
+
+// decodes the next value into a tree
+func (f *msgpackFormat) tree() (any, error) {
+	code, err := f.dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+	isMap := msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32
+	isArray := msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32
+	if !isMap && !isArray {
+		raw, err := f.dec.DecodeRaw()
+		if err != nil {
+			return nil, err
+		}
+		var native any
+		if err := msgpack.Unmarshal(raw, &native); err != nil {
+			// e.g. an extension type nobody told us about, pass it through as it is
+			s := newTreeScalar(raw, raw)
+			s.v = s
+			return s, nil
+		}
+		return newTreeScalar(raw, native), nil
+	}
+	if f.depth++; f.depth > maxJSONDepth {
+		return nil, errors.New("exceeded max depth")
+	}
+	defer func() { f.depth-- }()
+	if isArray {
+		n, err := f.dec.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		items := []any{}
+		for i := 0; i < n; i++ {
+			v, err := f.tree()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	}
+	n, err := f.dec.DecodeMapLen()
+	if err != nil {
+		return nil, err
+	}
+	m := &treeMap{}
+	for i := 0; i < n; i++ {
+		k, err := f.tree()
+		if err != nil {
+			return nil, err
+		}
+		v, err := f.tree()
+		if err != nil {
+			return nil, err
+		}
+		m.keys, m.values = append(m.keys, k), append(m.values, v)
+	}
+	return m, nil
+}
+
+func (f *msgpackFormat) encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	// like any other encoder would
+	enc.UseCompactInts(true)
+	return f.encodeTree(enc, v)
+}
+
+func (f *msgpackFormat) encodeTree(enc *msgpack.Encoder, v any) error {
+	switch v := v.(type) {
+	case *treeMap:
+		if err := enc.EncodeMapLen(len(v.keys)); err != nil {
+			return err
+		}
+		for i, k := range v.keys {
+			if err := f.encodeTree(enc, k); err != nil {
+				return err
+			}
+			if err := f.encodeTree(enc, v.values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		if err := enc.EncodeArrayLen(len(v)); err != nil {
+			return err
+		}
+		for _, elem := range v {
+			if err := f.encodeTree(enc, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *treeScalar:
+		if v.raw != nil {
+			return enc.Encode(msgpack.RawMessage(v.raw))
+		}
+		if n, ok := v.native.(*big.Int); ok {
+			return fmt.Errorf("%s is out of range for a msgpack integer", n)
+		}
+		return enc.Encode(v.native)
+	}
+	return errors.New("unexpected value in tree")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+/**
+ * ProcessNDJSON is ProcessJSON for newline-delimited JSON (a.k.a. JSON Lines),
+ * where each line is a JSON value of its own, e.g. a log entry.
+ * Lines are transformed one at a time as the file is streamed back in, so memory use
+ * is bounded by the longest line. A line which can't be transformed is left out of the
+ * modified copy and reported, rather than failing the whole file. Blank lines are
+ * dropped, unless preserving formatting.
+ */
+func ProcessNDJSON(filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error) {
+	report := &ProcessReport{}
+	err := processFile(filename, x, func(r io.Reader, w io.Writer) error {
+		return transformNDJSON(r, w, spec.rules(), spec != nil && spec.PreserveFormatting, report)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write modified NDJSON data to file: %w", err)
+	}
+	return report, nil
+}
+
+// transforms each line read from r on its own, writing out the lines which succeed to w,
+// and reporting those which don't; only failing to read or write is an error
+func transformNDJSON(r io.Reader, w io.Writer, rule Rule, preserveFormatting bool, report *ProcessReport) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	// output of the current line, only written out once it's known to be good
+	var out bytes.Buffer
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if len(bytes.TrimSpace(line)) == 0 {
+				if preserveFormatting {
+					bw.Write(line)
+				}
+			} else {
+				out.Reset()
+				if terr := transformJSON(bytes.NewReader(line), &out, rule, preserveFormatting); terr != nil {
+					report.lineFailed(n, terr)
+				} else {
+					bw.Write(out.Bytes())
+					if !preserveFormatting {
+						// whitespace, including the line break, is left behind when compacting
+						bw.WriteByte('\n')
+					}
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+)
+
+func TestProcessNDJSON(t *testing.T) {
+	const input = "{\"apple\": 2, \"name\": \"kiwi\"}\n" +
+		"\n" +
+		"{\"name\": \"broken\"\n" +
+		"  [1, {\"num\": 4}]\r\n" +
+		"not json at all\n" +
+		"{\"count\": 3}"
+	cases := []struct {
+		testName           string
+		preserveFormatting bool
+		want               string
+	}{
+		{"compact", false, "{\"name\":\"kiwi\"}\n[1,{\"num\":4000}]\n{\"count\":3}\n"},
+		{"preserving formatting", true, "{\"name\": \"kiwi\"}\n\n  [1, {\"num\": 4000}]\r\n{\"count\": 3}"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			buf := NewBufferWriter()
+			buf.m["log.ndjson"] = []byte(input)
+			report, err := ProcessNDJSON("log.ndjson", buf, &TransformSpec{Rules: DefaultRules(), PreserveFormatting: tt.preserveFormatting})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(buf.m["modified_log.ndjson"]); got != tt.want {
+				t.Errorf("expected:\n%q\ngot:\n%q", tt.want, got)
+			}
+			if report.FailedLines != 2 || len(report.LineErrors) != 2 {
+				t.Fatalf("expected 2 failed lines, got %d: %v", report.FailedLines, report.LineErrors)
+			}
+			for i, line := range []int{3, 5} {
+				if report.LineErrors[i].Line != line {
+					t.Errorf("expected line %d to fail, got line %d", line, report.LineErrors[i].Line)
+				}
+			}
+		})
+	}
+}
+
+func TestUploaderService_UploadFile_NDJSON(t *testing.T) {
+	buf := NewBufferWriter()
+	uploadSvc := NewCustomUploader(buf)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	// more bad lines than are reported
+	var data, want strings.Builder
+	for i := 1; i <= 3*maxLineErrors; i++ {
+		if i%2 == 0 {
+			fmt.Fprintf(&data, "{\"line\": %d, \"even\": true}\n", i)
+			fmt.Fprintf(&want, "{\"line\":%d000}\n", i)
+		} else {
+			fmt.Fprintf(&data, "{\"line\": %d,\n", i)
+		}
+	}
+	resp, err := sendDataInChunksToServer(t, client, data.String(), "log.ndjson", "application/x-ndjson")
+	if err != nil {
+		t.Fatalf("client.UploadFile: %s", err)
+	}
+	if got := string(buf.m["modified_log.ndjson"]); got != want.String() {
+		t.Errorf("expected:\n%s\ngot:\n%s", want.String(), got)
+	}
+	if resp.GetFailedLines() != 3*maxLineErrors/2 {
+		t.Errorf("expected %d failed lines, got %d", 3*maxLineErrors/2, resp.GetFailedLines())
+	}
+	if len(resp.GetLineErrors()) != maxLineErrors {
+		t.Fatalf("expected %d line errors, got %d", maxLineErrors, len(resp.GetLineErrors()))
+	}
+	if le := resp.GetLineErrors()[1]; le.GetLine() != 3 || le.GetError() == "" {
+		t.Errorf("expected error for line 3, got %v", le)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+/**
+ * The properties that have even integer number should be increased by *1000*
+ * (or whatever factor the rule is configured with).
+ *
+ * Integers are handled with arbitrary precision, so nothing is skipped for being too big
+ * and nothing silently wraps around. Configuration (all optional):
+ *
+ *	{"factor": 1000,             // any integer
+ *	 "integral_floats": true,    // treat e.g. 4.0 as the integer 4
+ *	 "exponent_notation": true,  // treat e.g. 2e3 as the integer 2000
+ *	 "limit": "int64",           // keep results within "int32", "int64" or "safe" (±2^53-1)
+ *	 "on_overflow": "saturate"}  // when past the limit "error" (the default) or "saturate"
+ *
+ * Integral floats and exponent notation are written back out as plain integers once scaled.
+ */
+type scaleEvenInts struct {
+	noKeys
+	factor           *big.Int
+	integralFloats   bool
+	exponentNotation bool
+	min, max         *big.Int // nil when unlimited
+	saturate         bool
+	saturations      *int // results clamped to the limit, when saturating
+}
+
+// a float as a json.Number which still looks like one, the way JSON would have it:
+// 'g' formatting drops the fraction from integral floats, which would pass them off as
+// integers, rather than leaving it to integral_floats and exponent_notation
+func floatNumber(f float64, bitSize int) json.Number {
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return json.Number(s)
+}
+
+// limits for the results of scaling
+var integerLimits = map[string][2]*big.Int{
+	"int32": {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	"int64": {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+	// largest integers exactly representable by a float64, i.e. safe in JavaScript
+	"safe": {big.NewInt(-(1<<53 - 1)), big.NewInt(1<<53 - 1)},
+}
+
+// integers from exponent notation with more digits than this are left alone,
+// rather than letting something like 1e999999999 eat all our memory
+const maxIntegerDigits = 1000
+
+// and anything with a (negative) exponent past this isn't worth looking at
+const maxExponent = 1_000_000
+
+func newScaleEvenInts(config json.RawMessage) (Rule, error) {
+	cfg := struct {
+		Factor           json.Number `json:"factor"`
+		IntegralFloats   bool        `json:"integral_floats"`
+		ExponentNotation bool        `json:"exponent_notation"`
+		Limit            string      `json:"limit"`
+		OnOverflow       string      `json:"on_overflow"`
+	}{}
+	if err := decodeRuleConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	rule := scaleEvenInts{
+		factor:           big.NewInt(1000),
+		integralFloats:   cfg.IntegralFloats,
+		exponentNotation: cfg.ExponentNotation,
+	}
+	if cfg.Factor != "" {
+		if _, ok := rule.factor.SetString(cfg.Factor.String(), 10); !ok {
+			return nil, fmt.Errorf("factor must be an integer, got %s", cfg.Factor)
+		}
+	}
+	if cfg.Limit != "" {
+		limits, ok := integerLimits[cfg.Limit]
+		if !ok {
+			return nil, fmt.Errorf("unknown limit '%s'", cfg.Limit)
+		}
+		rule.min, rule.max = limits[0], limits[1]
+	}
+	switch cfg.OnOverflow {
+	case "", "error":
+	case "saturate":
+		rule.saturate, rule.saturations = true, new(int)
+	default:
+		return nil, fmt.Errorf("unknown on_overflow '%s'", cfg.OnOverflow)
+	}
+	return rule, nil
+}
+
+func (r scaleEvenInts) Value(path Path, v any) (any, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v, nil
+	}
+	i, ok := r.integer(n)
+	if !ok || i.Bit(0) != 0 {
+		// not an (even) integer
+		return v, nil
+	}
+	i.Mul(i, r.factor)
+	if r.max != nil && (i.Cmp(r.min) < 0 || i.Cmp(r.max) > 0) {
+		if !r.saturate {
+			return nil, fmt.Errorf("%s × %s is out of range", n, r.factor)
+		}
+		*r.saturations++
+		if i.Sign() < 0 {
+			i.Set(r.min)
+		} else {
+			i.Set(r.max)
+		}
+	}
+	return json.Number(i.String()), nil
+}
+
+// how many results have been clamped to the limit so far
+func (r scaleEvenInts) saturated() int {
+	if r.saturations == nil {
+		return 0
+	}
+	return *r.saturations
+}
+
+// parses the number as an integer, if it is one (and the rule allows its notation)
+func (r scaleEvenInts) integer(n json.Number) (*big.Int, bool) {
+	s := n.String()
+	// split up -?int(.frac)?([eE][+-]?exp)?
+	mantissa, exponent := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		if !r.exponentNotation {
+			return nil, false
+		}
+		mantissa, exponent = s[:i], s[i+1:]
+	}
+	whole, fraction := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		if !r.integralFloats {
+			return nil, false
+		}
+		whole, fraction = mantissa[:i], mantissa[i+1:]
+	}
+	if exponent == "" && fraction == "" {
+		i, ok := new(big.Int).SetString(whole, 10)
+		return i, ok
+	}
+
+	// value is digits × 10^scale
+	digits := whole + fraction
+	if strings.Trim(digits, "-0") == "" {
+		// zero, whatever the exponent
+		return new(big.Int), true
+	}
+	scale := -len(fraction)
+	if exponent != "" {
+		exp, err := strconv.Atoi(exponent)
+		if err != nil || exp > maxIntegerDigits || exp < -maxExponent {
+			return nil, false
+		}
+		scale += exp
+	}
+	if scale < 0 {
+		// only an integer if enough trailing zeros are dropped
+		trimmed := strings.TrimRight(digits, "0")
+		if len(digits)-len(trimmed) < -scale {
+			return nil, false
+		}
+		digits, scale = digits[:len(digits)+scale], 0
+	}
+	if len(strings.TrimLeft(strings.TrimPrefix(digits, "-"), "0"))+scale > maxIntegerDigits {
+		return nil, false
+	}
+	i, ok := new(big.Int).SetString(digits+strings.Repeat("0", scale), 10)
+	return i, ok
+}
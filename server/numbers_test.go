@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestScaleEvenInts(t *testing.T) {
+	const (
+		allNotations = `{"integral_floats": true, "exponent_notation": true}`
+		int64Error   = `{"limit": "int64"}`
+		int64Sat     = `{"limit": "int64", "on_overflow": "saturate"}`
+	)
+	cases := []struct {
+		testName string
+		config   string
+		input    string
+		want     string
+		wantErr  bool
+	}{
+		{"even integer", ``, `4`, `4000`, false},
+		{"odd integer", ``, `5`, `5`, false},
+		{"negative even integer", ``, `-4`, `-4000`, false},
+		{"zero", ``, `0`, `0`, false},
+		{"beyond int64", ``, `92233720368547758080`, `92233720368547758080000`, false},
+		{"int64 result would wrap", ``, `9223372036854775806`, `9223372036854775806000`, false},
+		{"huge negative", ``, `-123456789012345678901234567890`, `-123456789012345678901234567890000`, false},
+		{"big factor", `{"factor": 100000000000000000000}`, `2`, `200000000000000000000`, false},
+		{"integral float ignored by default", ``, `4.0`, `4.0`, false},
+		{"exponent ignored by default", ``, `2e3`, `2e3`, false},
+		{"integral float", `{"integral_floats": true}`, `4.0`, `4000`, false},
+		{"integral float with trailing zeros", `{"integral_floats": true}`, `-12.000`, `-12000`, false},
+		{"fractional float", allNotations, `4.5`, `4.5`, false},
+		{"exponent needs its own option", `{"integral_floats": true}`, `2e3`, `2e3`, false},
+		{"exponent", `{"exponent_notation": true}`, `2e3`, `2000000`, false},
+		{"capital exponent with sign", `{"exponent_notation": true}`, `2E+2`, `200000`, false},
+		{"exponent and fraction", allNotations, `1.2e1`, `12000`, false},
+		{"exponent and fraction, odd", allNotations, `1.5e1`, `1.5e1`, false},
+		{"negative exponent, integral", allNotations, `400e-2`, `4000`, false},
+		{"negative exponent, fractional", allNotations, `1e-2`, `1e-2`, false},
+		{"zero with negative exponent", allNotations, `0e-5`, `0`, false},
+		{"negative zero with fraction and exponent", allNotations, `-0.00e-3`, `0`, false},
+		{"zero with absurd negative exponent", allNotations, `0e-9223372036854775808`, `0`, false},
+		{"absurd exponent", allNotations, `2e1000000`, `2e1000000`, false},
+		{"absurd negative exponent", allNotations, `2e-9223372036854775808`, `2e-9223372036854775808`, false},
+		{"within int64 limit", int64Error, `-4611686018427386`, `-4611686018427386000`, false},
+		{"past int64 limit", int64Error, `9223372036854776`, ``, true},
+		{"past int64 limit, odd", int64Error, `9223372036854777`, `9223372036854777`, false},
+		{"saturate at int64 max", int64Sat, `9223372036854776`, `9223372036854775807`, false},
+		{"saturate at int64 min", int64Sat, `-9223372036854776`, `-9223372036854775808`, false},
+		{"past int32 limit", `{"limit": "int32"}`, `2147484`, ``, true},
+		{"past safe limit", `{"limit": "safe", "on_overflow": "saturate"}`, `9007199254742`, `9007199254740991`, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			rule, err := NewRule("scale-even-ints", json.RawMessage(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := rule.Value(Path{"n"}, json.Number(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && got != json.Number(tt.want) {
+				t.Errorf("expected %s, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestScaleEvenInts_Config(t *testing.T) {
+	for _, config := range []string{
+		`{"factor": 1.5}`,
+		`{"factor": "x"}`,
+		`{"limit": "int128"}`,
+		`{"on_overflow": "wrap"}`,
+	} {
+		if _, err := NewRule("scale-even-ints", json.RawMessage(config)); err == nil {
+			t.Errorf("expected error for config %s", config)
+		}
+	}
+}
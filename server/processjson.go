@@ -1,9 +1,11 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -19,140 +21,349 @@ import (
 
   - It is expected that the corresponding automated test coverage is included
 
-    Ben's note: since the saved file has no guaranteed file size limit* (hypothetically it could
-    be greater than the availability of the available memory), the only way to prevent
-    crashing by running out of memory would be to do an on-disk byte traversal of the JSON tree.
-    Since this is not part of the assignment, we just error out if the JSON file to be loaded exceeds
-    available memory.
+    These are the DefaultRules, used unless the upload comes with a TransformSpec (see rules.go).
+    The modified JSON keeps the original order of keys, and with `preserve_formatting` set in the
+    TransformSpec, all of the original whitespace too, so it can be diffed against the original.
+
+    Ben's note: since the saved file has no guaranteed file size limit (hypothetically it could
+    be greater than the availability of the available memory), we never load the whole thing.
+    Instead the stored file is streamed back in one JSON token at a time, and the modified JSON
+    is written out to the new file as we go, so memory use is bounded by the size of the
+    largest single token (and how deeply nested the data is), not the size of the file.
 */
-func ProcessJSON(filename string, x OpenWriteCloserLoader) error {
-	defer x.Close()
-	// open file again, and load it all in to memory,
-	// (calls Open with the `currentFilename` to open the same file)
-	fileContent, err := x.Load(filename)
+func ProcessJSON(filename string, x OpenWriteCloserLoader, spec *TransformSpec) error {
+	// open file again, as a stream of bytes rather than loading it all in to memory
+	r, err := x.Load(filename)
 	if err != nil {
 		return err
 	}
-	// make changes described in bonus requirements
-	modifiedData, err := modifyJSON(fileContent)
-	if err != nil {
+	defer r.Close()
+	// write file contents with modified JSON data to a new file, as it is being read
+	if err := x.Open(modifiedName(filename)); err != nil {
 		return err
 	}
-	// write file contents with modified JSON data to a new file
-	if err := x.Open("modified_" + filename); err != nil {
-		return err
+	// make changes described in bonus requirements, unless told otherwise
+	var rules Rule = DefaultRules()
+	preserveFormatting := false
+	if spec != nil {
+		rules, preserveFormatting = spec.Rules, spec.PreserveFormatting
 	}
-	if _, err := x.Write(modifiedData); err != nil {
+	if err := transformJSON(r, x, rules, preserveFormatting); err != nil {
+		// don't leave a half-modified copy behind
+		x.Abort()
 		return fmt.Errorf("failed to write modified JSON data to file: %w", err)
 	}
-	return nil
+	return x.Close()
 }
 
-func modifyJSON(data []byte) ([]byte, error) {
-	// unmarshal into a working map where we will do the modifications
-	// before marshalling back into a JSON byte slice
-	var x map[string]any
-	// unmarshal numerical values to `json.Number` instead of float64
-	if err := numericalUnmarshalJSON(data, &x); err != nil {
-		return nil, fmt.Errorf("not valid json data")
+// reads a JSON value from r token by token, writing out the JSON modified by `rule` to w,
+// either compacted, or keeping all the whitespace (indentation, line breaks) of the original
+func transformJSON(r io.Reader, w io.Writer, rule Rule, preserveFormatting bool) error {
+	t := &jsonTransformer{w: bufio.NewWriter(w), rule: rule}
+	if preserveFormatting {
+		// hang on to the raw input, so it can be copied out as it was
+		t.raw = &rawRecorder{r: r}
+		r = t.raw
+	}
+	t.dec = json.NewDecoder(r)
+	// decode numerical values to `json.Number` instead of float64
+	t.dec.UseNumber()
+
+	// any JSON value will do at the top-level: object, array or scalar
+	tok, err := t.token()
+	if err != nil {
+		return t.invalid(err)
+	}
+	t.writeLead()
+	if err := t.value(tok); err != nil {
+		return t.invalid(err)
+	}
+	// nothing but whitespace allowed after the top-level value
+	if _, err := t.dec.Token(); err != io.EOF {
+		return t.invalid(errors.New("unexpected data after top-level value"))
+	}
+	if t.raw != nil {
+		// trailing whitespace, e.g. the final newline
+		t.w.Write(t.raw.buf)
 	}
-	// Remove keys starting with a vowel
-	removeKeysWithVowelPrefix(x)
-	// Multiply even integers by 1000
-	multiplyEvenIntegers(x)
-	// spit back out JSON byte slice
-	return json.Marshal(x)
+	return t.w.Flush()
 }
 
-// unmarshals numerical values to `json.Number` instead of float64
-func numericalUnmarshalJSON(data []byte, v any) error {
-	reader := bytes.NewReader(data)
-	decoder := json.NewDecoder(reader)
-	decoder.UseNumber()
+// InvalidJSONError is an upload which isn't valid JSON
+type InvalidJSONError struct {
+	// how far into the upload the problem was found, in bytes
+	Offset int64
+	Err    error
+}
+
+func (e *InvalidJSONError) Error() string {
+	return fmt.Sprintf("not valid json data: %s (at byte %d)", e.Err, e.Offset)
+}
+
+func (e *InvalidJSONError) Unwrap() error {
+	return e.Err
+}
 
-	if err := decoder.Decode(v); err != nil {
+// an error from reading the JSON, unless it's from a rule rather than the JSON itself
+func (t *jsonTransformer) invalid(err error) error {
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) {
 		return err
 	}
+	offset := t.dec.InputOffset()
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	}
+	return &InvalidJSONError{Offset: offset, Err: err}
+}
+
+// jsonTransformer copies JSON tokens from the decoder to the writer,
+// dropping or rewriting them as the rule says
+type jsonTransformer struct {
+	dec   *json.Decoder
+	w     *bufio.Writer
+	rule  Rule
+	path  Path // of the value currently being read
+	depth int  // how many objects/arrays deep we are
+
+	// only when preserving formatting
+	raw  *rawRecorder
+	lead string // whitespace & separators before the most recent token
+	text string // original text of the most recent token
+}
 
-	return nil
+// reads the next token, along with its original text when preserving formatting
+func (t *jsonTransformer) token() (json.Token, error) {
+	tok, err := t.dec.Token()
+	if err != nil || t.raw == nil {
+		return tok, err
+	}
+	segment := t.raw.take(t.dec.InputOffset())
+	i := strings.IndexFunc(segment, func(r rune) bool {
+		return !strings.ContainsRune(" \t\r\n,:", r)
+	})
+	if i < 0 {
+		i = len(segment)
+	}
+	t.lead, t.text = segment[:i], segment[i:]
+	return tok, nil
 }
 
-func removeKeysWithVowelPrefix(data map[string]any) {
-	vowels := []string{"a", "e", "i", "o", "u"}
-	keysToRemove := []string{}
+// writes out whatever came before the most recent token, when preserving formatting
+func (t *jsonTransformer) writeLead() {
+	if t.raw != nil {
+		t.w.WriteString(t.lead)
+	}
+}
 
-	for key, value := range data {
-		// Check if the key starts with a vowel
-		firstChar := strings.ToLower(key[0:1])
-		for _, vowel := range vowels {
-			if firstChar == vowel {
-				keysToRemove = append(keysToRemove, key)
-				break
-			}
-		}
+// same limit on nesting as encoding/json, deeper than that is more likely an attack than data
+const maxJSONDepth = 10000
 
-		// Recursive call for nested objects
-		if nested, ok := value.(map[string]any); ok {
-			removeKeysWithVowelPrefix(nested)
+// writes out the value starting with `tok` (anything before it has already been written)
+func (t *jsonTransformer) value(tok json.Token) error {
+	switch v := tok.(type) {
+	case json.Delim:
+		if t.depth++; t.depth > maxJSONDepth {
+			return fmt.Errorf("exceeded max depth")
 		}
-
-		// Recursive call for arrays
-		if arr, ok := value.([]any); ok {
-			removeKeysWithVowelPrefixArray(arr)
+		defer func() { t.depth-- }()
+		switch v {
+		case '{':
+			return t.object()
+		case '[':
+			return t.array()
 		}
+		return fmt.Errorf("unexpected '%s'", v)
 	}
-
-	// Remove the identified keys
-	for _, key := range keysToRemove {
-		delete(data, key)
+	v, err := t.rule.Value(t.path, tok)
+	if err != nil {
+		return ruleFailed(t.path, err)
+	}
+	if t.raw != nil && sameScalar(tok, v) {
+		// untouched, so write it out exactly as it was
+		_, err := t.w.WriteString(t.text)
+		return err
 	}
+	return t.writeScalar(v)
 }
 
-func removeKeysWithVowelPrefixArray(arr []any) {
-	for _, item := range arr {
-		if nested, ok := item.(map[string]any); ok {
-			removeKeysWithVowelPrefix(nested)
-		}
+// reports whether a rule left a scalar value as it was
+func sameScalar(before, after any) bool {
+	switch after.(type) {
+	case json.Number, string, bool, nil:
+		return before == after
+	}
+	return false
+}
 
-		if nestedArr, ok := item.([]any); ok {
-			removeKeysWithVowelPrefixArray(nestedArr)
-		}
+func (t *jsonTransformer) writeScalar(v any) error {
+	switch v := v.(type) {
+	case json.Number:
+		_, err := t.w.WriteString(v.String())
+		return err
+	case string:
+		return t.writeString(v)
+	case bool:
+		_, err := t.w.WriteString(strconv.FormatBool(v))
+		return err
+	case nil:
+		_, err := t.w.WriteString("null")
+		return err
 	}
+	// some other value from a rule, let encoding/json deal with it
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = t.w.Write(data)
+	return err
 }
 
-func multiplyEvenIntegers(data map[string]any) {
-	for key, value := range data {
-		switch val := value.(type) {
-		case json.Number:
-			if intVal, err := strconv.ParseInt(val.String(), 10, 64); err == nil {
-				if intVal%2 == 0 {
-					// Multiply even integer values by 1000
-					data[key] = json.Number(strconv.FormatInt(intVal*1000, 10))
-				}
+// writes out the members of an object, the opening '{' having already been read
+func (t *jsonTransformer) object() error {
+	t.w.WriteByte('{')
+	first := true
+	// whatever came between the '{' and the first key, e.g. a newline & indentation
+	firstLead := ""
+	for i := 0; t.dec.More(); i++ {
+		tok, err := t.token()
+		if err != nil {
+			return err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("expected object key, got %v", tok)
+		}
+		keyLead, keyText := t.lead, t.text
+		if i == 0 {
+			firstLead = keyLead
+		}
+		if tok, err = t.token(); err != nil {
+			return err
+		}
+		action, newKey := t.rule.Key(t.path, key)
+		if action == DropKey {
+			if err := t.skip(tok); err != nil {
+				return err
+			}
+			continue
+		}
+		if t.raw != nil {
+			if first {
+				// any members before this one have been dropped, so this one takes their place
+				keyLead = firstLead
+			}
+			t.w.WriteString(keyLead)
+			if action == KeepKey {
+				t.w.WriteString(keyText)
+			} else if err := t.writeString(newKey); err != nil {
+				return err
+			}
+			// includes the ':'
+			t.writeLead()
+		} else {
+			if !first {
+				t.w.WriteByte(',')
 			}
+			if err := t.writeString(newKey); err != nil {
+				return err
+			}
+			t.w.WriteByte(':')
+		}
+		first = false
+		t.path = append(t.path, key)
+		if err := t.value(tok); err != nil {
+			return err
+		}
+		t.path = t.path[:len(t.path)-1]
+	}
+	// consume the closing '}'
+	if _, err := t.token(); err != nil {
+		return err
+	}
+	t.writeLead()
+	return t.w.WriteByte('}')
+}
 
-		case []any:
-			multiplyEvenIntegersArray(val)
-		case map[string]any:
-			multiplyEvenIntegers(val)
+// writes out the elements of an array, the opening '[' having already been read
+func (t *jsonTransformer) array() error {
+	t.w.WriteByte('[')
+	for i := 0; t.dec.More(); i++ {
+		tok, err := t.token()
+		if err != nil {
+			return err
+		}
+		if t.raw != nil {
+			// includes the ','
+			t.writeLead()
+		} else if i > 0 {
+			t.w.WriteByte(',')
+		}
+		t.path = append(t.path, strconv.Itoa(i))
+		if err := t.value(tok); err != nil {
+			return err
 		}
+		t.path = t.path[:len(t.path)-1]
+	}
+	// consume the closing ']'
+	if _, err := t.token(); err != nil {
+		return err
 	}
+	t.writeLead()
+	return t.w.WriteByte(']')
 }
 
-func multiplyEvenIntegersArray(arr []any) {
-	for i, item := range arr {
-		switch val := item.(type) {
-		case json.Number:
-			if intVal, err := strconv.ParseInt(val.String(), 10, 64); err == nil {
-				if intVal%2 == 0 {
-					// Multiply even integer values by 1000
-					arr[i] = json.Number(strconv.FormatInt(intVal*1000, 10))
-				}
+// reads past the value starting with `tok` without writing anything
+func (t *jsonTransformer) skip(tok json.Token) error {
+	depth := 0
+	for {
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			if depth++; t.depth+depth > maxJSONDepth {
+				return fmt.Errorf("exceeded max depth")
 			}
-		case []any:
-			multiplyEvenIntegersArray(val)
-		case map[string]any:
-			multiplyEvenIntegers(val)
+		case json.Delim('}'), json.Delim(']'):
+			depth--
 		}
+		if depth == 0 {
+			return nil
+		}
+		var err error
+		if tok, err = t.token(); err != nil {
+			return err
+		}
+	}
+}
+
+func (t *jsonTransformer) writeString(s string) error {
+	quoted, err := json.Marshal(s)
+	if err != nil {
+		return err
 	}
+	_, err = t.w.Write(quoted)
+	return err
+}
+
+// rawRecorder keeps the bytes read by the decoder until they have been taken back out,
+// so whitespace and the original text of each token can be written out unchanged
+type rawRecorder struct {
+	r    io.Reader
+	buf  []byte
+	base int64 // input offset of buf[0]
+}
+
+func (rr *rawRecorder) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	return n, err
+}
+
+// takes everything up to the input offset `end` out of the buffer
+func (rr *rawRecorder) take(end int64) string {
+	n := int(end - rr.base)
+	segment := string(rr.buf[:n])
+	rr.buf = rr.buf[n:]
+	rr.base = end
+	return segment
 }
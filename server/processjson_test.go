@@ -1,8 +1,18 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-test/deep"
 )
@@ -23,12 +33,12 @@ func TestProcessJSON(t *testing.T) {
 	}
 	for _, tt := range cases {
 		t.Run(tt.testName, func(t *testing.T) {
-			err := ProcessJSON(tt.filename, tt.x)
+			err := ProcessJSON(tt.filename, tt.x, nil)
 			if err != tt.err {
 				t.Error("unexpected error when processing json blob")
 			}
 			// get resulting modified json
-			got, _ := tt.x.Load("modified_" + tt.filename)
+			got, _ := loadAll(tt.x, "modified_"+tt.filename)
 			// Compare the modified JSON with the expected output
 			// Unmarshal resulting json blob into a map to make comparison easier
 			gotDataMap := map[string]any{}
@@ -46,3 +56,227 @@ func TestProcessJSON(t *testing.T) {
 		})
 	}
 }
+
+// any JSON value can be at the top-level, not just an object
+func TestTransformJSON_RootKinds(t *testing.T) {
+	cases := []struct {
+		testName string
+		input    string
+		want     string
+		// expected output when preserving formatting, if different from the input
+		wantPreserved string
+	}{
+		{"object", `{"age": 2, "kiwi": 4}`, `{"kiwi":4000}`, `{"kiwi": 4000}`},
+		{"empty object", `{}`, `{}`, `{}`},
+		{"array of objects", `[{"age": 2}, {"kiwi": 4, "egg": 1}]`, `[{},{"kiwi":4000}]`, `[{}, {"kiwi": 4000}]`},
+		{"nested arrays", `[[{"owl": 1, "tui": 2}], [6, [7, 8]]]`, `[[{"tui":2000}],[6000,[7,8000]]]`, `[[{"tui": 2000}], [6000, [7, 8000]]]`},
+		{"empty array", ` [ ] `, `[]`, ` [ ] `},
+		{"string", `"apple"`, `"apple"`, ``},
+		{"string with escapes", `"\u0041pple\n"`, `"Apple\n"`, ``},
+		{"even integer", `42`, `42000`, ``},
+		{"odd integer", ` 7 `, `7`, ``},
+		{"float", `4.5`, `4.5`, ``},
+		{"boolean", `true`, `true`, ``},
+		{"null", "null\n", `null`, ``},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			var got strings.Builder
+			if err := transformJSON(strings.NewReader(tt.input), &got, DefaultRules(), false); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got.String())
+			}
+
+			wantPreserved := tt.wantPreserved
+			if wantPreserved == "" {
+				// unchanged, other than the modifications
+				wantPreserved = strings.Replace(tt.input, "42", "42000", 1)
+			}
+			got.Reset()
+			if err := transformJSON(strings.NewReader(tt.input), &got, DefaultRules(), true); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != wantPreserved {
+				t.Errorf("expected %q, got %q", wantPreserved, got.String())
+			}
+		})
+	}
+}
+
+func TestProcessJSON_ArrayRoot(t *testing.T) {
+	buf := NewBufferWriter()
+	buf.m["people.json"] = []byte(`[{"age": 2, "name": "Jo"}, {"age": 5, "name": "Al", "kids": 2}]`)
+	if err := ProcessJSON("people.json", buf, nil); err != nil {
+		t.Fatalf("unexpected error processing array: %s", err)
+	}
+	const want = `[{"name":"Jo"},{"name":"Al","kids":2000}]`
+	if got := string(buf.m["modified_people.json"]); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+// compares the modified output against hand-written expected files in testdata/
+func TestTransformJSON_Formatting(t *testing.T) {
+	cases := []struct {
+		testName           string
+		input              string
+		preserveFormatting bool
+		want               string
+	}{
+		{"compact, keeping key order", "blob.json", false, "blob.compact.json"},
+		{"keeping original formatting", "blob.json", true, "blob.modified.json"},
+		{"keeping tab indentation & inline objects", "tabs.json", true, "tabs.modified.json"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			input, err := os.Open(filepath.Join("testdata", tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer input.Close()
+			want, err := os.ReadFile(filepath.Join("testdata", tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := transformJSON(input, &got, DefaultRules(), tt.preserveFormatting); err != nil {
+				t.Fatal(err)
+			}
+			if diff := diffLines(got.String(), string(want)); diff != "" {
+				t.Errorf("modified output differs from %s:\n%s", tt.want, diff)
+			}
+		})
+	}
+}
+
+// a crude line by line diff, good enough to point at what's wrong
+func diffLines(got, want string) string {
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	var diff strings.Builder
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			fmt.Fprintf(&diff, "line %d:\n-%q\n+%q\n", i+1, w, g)
+		}
+	}
+	return diff.String()
+}
+
+func TestTransformJSON_Invalid(t *testing.T) {
+	cases := []struct {
+		testName string
+		input    string
+	}{
+		{"empty", ""},
+		{"truncated object", `{"banana": 8, "carrot": [6, `},
+		{"truncated inside skipped value", `{"apple": {"pear": [1, 2`},
+		{"missing colon", `{"banana" 8}`},
+		{"trailing data", `{"banana": 8} {"carrot": 6}`},
+		{"trailing data after scalar", `8 6`},
+		{"unterminated array root", `[{"banana": 8}`},
+		{"bare word", `banana`},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			if err := transformJSON(strings.NewReader(tt.input), io.Discard, DefaultRules(), false); err == nil {
+				t.Errorf("expected error transforming %q", tt.input)
+			}
+		})
+	}
+}
+
+// streams a generated document of a few hundred MB through the transformation,
+// checking the output is correct and that memory use doesn't grow with the size of the input
+func TestTransformJSON_Large(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large JSON document in short mode")
+	}
+	const records = 1_000_000
+	// ~250 bytes a record
+	record := strings.ReplaceAll(`{"id": 42, "name": "Jane Doe", "email": "jane@example.com",
+		"scores": [1, 2, 3, 4], "nested": {"kiwi": 10, "orange": 4, "tags": ["a", "b", "c"]},
+		"blurb": "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor."}`, "\t", "")
+	var modifiedRecord strings.Builder
+	if err := transformJSON(strings.NewReader(record), &modifiedRecord, DefaultRules(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	// generate the input document on the fly, so the test itself never holds it in memory
+	pr, pw := io.Pipe()
+	go func() {
+		bw := bufio.NewWriter(pw)
+		bw.WriteString(`{"records": [`)
+		for i := 0; i < records; i++ {
+			if i > 0 {
+				bw.WriteString(",\n")
+			}
+			bw.WriteString(record)
+		}
+		bw.WriteString(`], "apple": 1, "count": 1000000}`)
+		pw.CloseWithError(bw.Flush())
+	}()
+	// expected output, also built up on the fly
+	want := sha256.New()
+	want.Write([]byte(`{"records":[`))
+	for i := 0; i < records; i++ {
+		if i > 0 {
+			want.Write([]byte(","))
+		}
+		want.Write([]byte(modifiedRecord.String()))
+	}
+	want.Write([]byte(`],"count":1000000000}`))
+
+	// keep an eye on the heap while the transformation runs
+	got := sha256.New()
+	var err error
+	maxHeap := measurePeakHeap(t, func() {
+		err = transformJSON(pr, got, DefaultRules(), false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Sum(nil), want.Sum(nil)) {
+		t.Errorf("modified output does not match expected output")
+	}
+	t.Logf("peak heap: %d bytes", maxHeap)
+	const limit = 32 << 20
+	if maxHeap > limit {
+		t.Errorf("heap grew to %d bytes, expected less than %d", maxHeap, limit)
+	}
+}
+
+// runs f, sampling the heap as it goes, and returns the most it was seen to use
+func measurePeakHeap(t *testing.T, f func()) uint64 {
+	t.Helper()
+	done := make(chan struct{})
+	peak := make(chan uint64)
+	go func() {
+		var max uint64
+		var stats runtime.MemStats
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				peak <- max
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > max {
+					max = stats.HeapAlloc
+				}
+			}
+		}
+	}()
+	f()
+	close(done)
+	return <-peak
+}
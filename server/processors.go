@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// Processor writes the `modified_` copy of an uploaded file, for the content type it's registered for
+type Processor func(filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error)
+
+// ProcessReport is what a Processor has to say about a file it got through,
+// for problems which weren't bad enough to fail the whole thing
+type ProcessReport struct {
+	// lines left out of the modified copy (up to maxLineErrors of them), for line-delimited formats
+	LineErrors []LineError
+	// total number of lines left out, including any beyond maxLineErrors
+	FailedLines int
+	// what the rules did, as counted by runProcessor
+	KeysRemoved, IntsRewritten int
+	// numbers clamped to a limit (and so also counted as rewritten), by rules which saturate
+	IntsSaturated int
+	// time taken to write the modified copy
+	Elapsed time.Duration
+}
+
+// LineError is a line which couldn't be processed, numbered from 1
+type LineError struct {
+	Line int
+	Err  error
+}
+
+// cap on the number of LineErrors reported, so a file full of junk doesn't make for a huge response
+const maxLineErrors = 100
+
+func (r *ProcessReport) lineFailed(line int, err error) {
+	r.FailedLines++
+	if len(r.LineErrors) < maxLineErrors {
+		r.LineErrors = append(r.LineErrors, LineError{Line: line, Err: err})
+	}
+}
+
+// loads the stored file, and transforms it into its `modified_` copy,
+// which is only saved (replacing any previous copy) if the whole transformation succeeds
+func processFile(filename string, x OpenWriteCloserLoader, transform func(r io.Reader, w io.Writer) error) error {
+	r, err := x.Load(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := x.Open(modifiedName(filename)); err != nil {
+		return err
+	}
+	if err := transform(r, x); err != nil {
+		x.Abort()
+		return err
+	}
+	return x.Close()
+}
+
+// processors by the media type they handle
+var processors = map[string]Processor{
+	"application/json":          processJSON,
+	"application/x-ndjson":      ProcessNDJSON,
+	"application/jsonl":         ProcessNDJSON,
+	"application/x-jsonlines":   ProcessNDJSON,
+	"application/yaml":          ProcessYAML,
+	"application/x-yaml":        ProcessYAML,
+	"text/yaml":                 ProcessYAML,
+	"text/x-yaml":               ProcessYAML,
+	"application/toml":          ProcessTOML,
+	"text/x-toml":               ProcessTOML,
+	"text/csv":                  ProcessCSV,
+	"application/csv":           ProcessCSV,
+	"text/tab-separated-values": ProcessTSV,
+	"application/cbor":          ProcessCBOR,
+	"application/msgpack":       ProcessMsgpack,
+	"application/x-msgpack":     ProcessMsgpack,
+	"application/vnd.msgpack":   ProcessMsgpack,
+}
+
+// ErrNoProcessor is the error for a spec for an upload of a type there's no Processor for
+var ErrNoProcessor = errors.New("no processor for type")
+
+// processorFor picks how to process an upload of `contentType`, or nil if it isn't to be processed:
+// known types are processed by default, and with a spec, so is JSON of any other type (going by what
+// was `detected`, too), or an upload of no particular type; a spec for anything else is ErrNoProcessor
+func processorFor(contentType, detected string, spec *TransformSpec) (Processor, error) {
+	if spec != nil && len(spec.Rules) == 0 {
+		return nil, nil
+	}
+	if p, ok := processors[mediaType(contentType)]; ok {
+		return p, nil
+	}
+	if spec == nil {
+		return nil, nil
+	}
+	if jsonFamily(contentType) || jsonFamily(detected) || (contentType == "" && inconclusive(detected)) {
+		return processJSON, nil
+	}
+	return nil, fmt.Errorf("%w '%s'", ErrNoProcessor, mediaType(contentType))
+}
+
+// runs a Processor with the rules from `spec`, counting what they do along the way
+func runProcessor(process Processor, filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error) {
+	counted := TransformSpec{}
+	if spec != nil {
+		counted = *spec
+	}
+	rules := spec.rules()
+	saturated := saturations(rules) // the spec's rules may have been used before
+	counter := &ruleCounter{rule: rules}
+	counted.Rules = RuleSet{counter}
+	start := time.Now()
+	report, err := process(filename, x, &counted)
+	if err != nil {
+		return nil, err
+	}
+	report.KeysRemoved, report.IntsRewritten = counter.keysRemoved, counter.intsRewritten
+	report.IntsSaturated = saturations(rules) - saturated
+	if report.IntsSaturated > 0 {
+		log.Printf("saturated %d numbers in '%s'\n", report.IntsSaturated, filename)
+	}
+	report.Elapsed = time.Since(start)
+	return report, nil
+}
+
+// ruleCounter counts the properties a rule drops and the numbers it changes;
+// for CSV, a dropped column counts once, as it's only the header that has keys
+type ruleCounter struct {
+	rule                       Rule
+	keysRemoved, intsRewritten int
+}
+
+func (c *ruleCounter) Key(path Path, key string) (KeyAction, string) {
+	action, newKey := c.rule.Key(path, key)
+	if action == DropKey {
+		c.keysRemoved++
+	}
+	return action, newKey
+}
+
+func (c *ruleCounter) Value(path Path, v any) (any, error) {
+	after, err := c.rule.Value(path, v)
+	if err != nil {
+		return nil, err
+	}
+	if n, ok := v.(json.Number); ok && after != n {
+		c.intsRewritten++
+	}
+	return after, nil
+}
+
+// ProcessJSON as a Processor, there's never anything to report
+func processJSON(filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error) {
+	return &ProcessReport{}, ProcessJSON(filename, x, spec)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Resumer is implemented by writers that can keep a partially received upload around
+// under its upload ID, so an interrupted upload can be continued by a later stream.
+type Resumer interface {
+	// Offset reports how many bytes of the upload have been persisted so far
+	// (zero for an unknown upload)
+	Offset(uploadID string) (int64, error)
+	// Resume opens the partial upload for writing at `offset`,
+	// discarding anything previously received beyond it
+	Resume(uploadID string, offset int64) error
+	// Commit moves a completed (and closed) partial upload to its final file name
+	Commit(uploadID, filename string) error
+	// Discard throws away a (closed) partial upload
+	Discard(uploadID string) error
+	// LoadPartial returns a reader over the bytes received so far
+	LoadPartial(uploadID string) (io.ReadCloser, error)
+}
+
+// upload IDs end up in file paths, so keep them boring
+var uploadIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+func resumerFor(w OpenWriteCloserLoader, uploadID string) (Resumer, error) {
+	if !uploadIDPattern.MatchString(uploadID) {
+		return nil, withDetails(status.Errorf(codes.InvalidArgument, "invalid upload_id '%s'", uploadID),
+			fieldViolation("upload_id", "must be 1 to 128 of [A-Za-z0-9_-]"))
+	}
+	r, ok := w.(Resumer)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "resumable uploads are not supported")
+	}
+	return r, nil
+}
+
+// opens writer `w` on the partial upload, after checking that nobody else is streaming it
+// and the requested offset doesn't leave a gap; the returned func must be called once the
+// stream is finished with the upload
+func (u *Uploader) resumeUpload(w OpenWriteCloserLoader, uploadID string, offset uint64) (func(), error) {
+	r, err := resumerFor(w, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if _, busy := u.inProgress.LoadOrStore(uploadID, struct{}{}); busy {
+		return nil, withDetails(status.Errorf(codes.Aborted, "upload '%s' is already in progress", uploadID),
+			resourceInfo("upload", uploadID, ""), errorInfo(ReasonUploadInProgress, nil))
+	}
+	release := func() { u.inProgress.Delete(uploadID) }
+
+	persisted, err := r.Offset(uploadID)
+	if err != nil {
+		release()
+		return nil, storageError("query upload offset", uploadID, err)
+	}
+	if offset > uint64(persisted) {
+		release()
+		return nil, withDetails(status.Errorf(codes.OutOfRange, "offset %d is beyond the %d bytes received so far", offset, persisted),
+			fieldViolation("offset", fmt.Sprintf("must be at most %d", persisted)))
+	}
+	if err := r.Resume(uploadID, int64(offset)); err != nil {
+		release()
+		return nil, storageError("open file", uploadID, err)
+	}
+	return release, nil
+}
+
+// feeds the first `offset` bytes of the partial upload into `h`
+func digestPartial(r Resumer, uploadID string, offset uint64, h io.Writer) error {
+	if offset == 0 {
+		return nil
+	}
+	partial, err := r.LoadPartial(uploadID)
+	if err != nil {
+		return err
+	}
+	defer partial.Close()
+	_, err = io.CopyN(h, partial, int64(offset))
+	return err
+}
+
+func (u *Uploader) QueryUploadOffset(ctx context.Context, req *uploadpb.UploadOffsetRequest) (*uploadpb.UploadOffsetResponse, error) {
+	r, err := resumerFor(u.io_thingee.NewWriter(), req.GetUploadId())
+	if err != nil {
+		return nil, err
+	}
+	offset, err := r.Offset(req.GetUploadId())
+	if err != nil {
+		return nil, storageError("query upload offset", req.GetUploadId(), err)
+	}
+	return &uploadpb.UploadOffsetResponse{
+		UploadId: req.GetUploadId(),
+		Offset:   uint64(offset),
+	}, nil
+}
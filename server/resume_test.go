@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUploaderService_ResumeUpload(t *testing.T) {
+	cases := []struct {
+		testName string
+		writers  WriterFactory
+	}{
+		{"in-memory storage", NewBufferWriter()},
+		{"on-disk storage", newTempDiskWriter(t)},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			uploadSvc := NewCustomUploader(tt.writers)
+			conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+				uploadpb.RegisterUploaderServer(srv, uploadSvc)
+			})
+			client := uploadpb.NewUploaderClient(conn)
+			const uploadID = "resume-test-01"
+
+			offset := queryOffset(t, client, uploadID)
+			if offset != 0 {
+				t.Fatalf("expected offset 0 for unknown upload, got %d", offset)
+			}
+
+			// send the first half of the file, then drop the connection
+			ctx, cancel := context.WithCancel(context.Background())
+			stream, err := client.UploadFile(ctx)
+			if err != nil {
+				t.Fatalf("failed to open stream: %s", err)
+			}
+			half := len(jsonBlob) / 20 * 10
+			for i := 0; i < half; i += 10 {
+				if err := stream.Send(&uploadpb.UploadRequest{
+					FileName: "testBlob",
+					MimeType: "application/json",
+					UploadId: uploadID,
+					Chunk:    []byte(jsonBlob[i : i+10]),
+				}); err != nil {
+					t.Fatalf("failed to send chunk: %s", err)
+				}
+			}
+			cancel()
+
+			// keep trying to resume from wherever the server got to,
+			// until the interrupted stream has been cleaned up on the server
+			var resp *uploadpb.UploadResponse
+			for attempt := 0; ; attempt++ {
+				offset = queryOffset(t, client, uploadID)
+				if offset > uint64(half) {
+					t.Fatalf("offset %d is beyond the %d bytes sent", offset, half)
+				}
+				resp, err = sendResumableDataInChunksToServer(t, client, jsonBlob, "testBlob", "application/json", uploadID, offset)
+				if status.Code(err) != codes.Aborted || attempt == 100 {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if err != nil {
+				t.Fatalf("client.UploadFile: %s", err)
+			}
+			if resp.GetSize() != uint64(len(jsonBlob)) {
+				t.Errorf("expected size %d, got %d", len(jsonBlob), resp.GetSize())
+			}
+			// digest covers the bytes received by both streams
+			if digest := sha256.Sum256([]byte(jsonBlob)); !bytes.Equal(resp.GetSha256(), digest[:]) {
+				t.Errorf("expected sha256 %x, got %x", digest, resp.GetSha256())
+			}
+			uploadedData, err := loadAll(tt.writers.NewWriter(), "testBlob")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(uploadedData) != jsonBlob {
+				t.Errorf("STORED DATA ≠ SENT DATA\n%s\n≠\n%s", uploadedData, jsonBlob)
+			}
+			// resumed uploads get post-processed just the same
+			if _, err := loadAll(tt.writers.NewWriter(), "modified_testBlob"); err != nil {
+				t.Errorf("missing modified copy: %s", err)
+			}
+			// partial upload is gone once completed
+			if offset := queryOffset(t, client, uploadID); offset != 0 {
+				t.Errorf("expected offset 0 after completion, got %d", offset)
+			}
+		})
+	}
+}
+
+func TestUploaderService_ResumeUpload_Offsets(t *testing.T) {
+	buf := NewBufferWriter()
+	uploadSvc := NewCustomUploader(buf)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	// a partial upload with some trailing garbage, e.g. from a chunk that was only half-written
+	buf.m[partialPrefix+"abc"] = []byte(haikuString[:20] + "garbage")
+	if offset := queryOffset(t, client, "abc"); offset != 27 {
+		t.Fatalf("expected offset 27, got %d", offset)
+	}
+
+	cases := []struct {
+		testName string
+		uploadID string
+		offset   uint64
+		code     codes.Code
+	}{
+		{"offset past received bytes", "abc", 28, codes.OutOfRange},
+		{"unknown upload with non-zero offset", "xyz", 1, codes.OutOfRange},
+		{"upload id with path separators", "../abc", 0, codes.InvalidArgument},
+		{"resume before trailing garbage", "abc", 20, codes.OK},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := sendResumableDataInChunksToServer(t, client, haikuString, "haiku.txt", "text/plain", tt.uploadID, tt.offset)
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected %s, got: %v", tt.code, err)
+			}
+		})
+	}
+	if string(buf.m["haiku.txt"]) != haikuString {
+		t.Errorf("STORED DATA ≠ SENT DATA\n%s\n≠\n%s", buf.m["haiku.txt"], haikuString)
+	}
+}
+
+func TestUploaderService_ResumeUpload_DigestMismatch(t *testing.T) {
+	buf := NewBufferWriter()
+	uploadSvc := NewCustomUploader(buf)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	// partial upload which got corrupted somewhere along the way
+	buf.m[partialPrefix+"abc"] = []byte("In lush jungle homes,\n")
+	digest := sha256.Sum256([]byte(haikuString))
+	_, err := sendRequestsToServer(t, client, []*uploadpb.UploadRequest{{
+		FileName: "haiku.txt",
+		UploadId: "abc",
+		Offset:   22,
+		Chunk:    []byte(haikuString[22:]),
+		Sha256:   digest[:],
+	}})
+	if status.Code(err) != codes.DataLoss {
+		t.Fatalf("expected %s, got: %v", codes.DataLoss, err)
+	}
+	// nothing to resume from, client has to start again
+	if offset := queryOffset(t, client, "abc"); offset != 0 {
+		t.Errorf("expected offset 0 after digest mismatch, got %d", offset)
+	}
+	if _, found := buf.m["haiku.txt"]; found {
+		t.Errorf("corrupted upload should not be stored")
+	}
+}
+
+func queryOffset(t *testing.T, client uploadpb.UploaderClient, uploadID string) uint64 {
+	resp, err := client.QueryUploadOffset(context.Background(), &uploadpb.UploadOffsetRequest{UploadId: uploadID})
+	if err != nil {
+		t.Fatalf("client.QueryUploadOffset: %s", err)
+	}
+	return resp.GetOffset()
+}
+
+// sends `data` from `offset` onwards as part of a resumable upload
+func sendResumableDataInChunksToServer(t *testing.T, client uploadpb.UploaderClient, data string, fileName string, mimeType string, uploadID string, offset uint64) (*uploadpb.UploadResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	t.Cleanup(func() {
+		cancel()
+	})
+	stream, err := client.UploadFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req := &uploadpb.UploadRequest{
+		FileName: fileName,
+		MimeType: mimeType,
+		UploadId: uploadID,
+		Offset:   offset,
+	}
+	for i := int(offset); i < len(data); i += 10 {
+		end := i + 10
+		if end > len(data) {
+			end = len(data)
+		}
+		req.Chunk = []byte(data[i:end])
+		if err := stream.Send(req); err != nil {
+			break // server has given up on the stream, find out why below
+		}
+		req = &uploadpb.UploadRequest{FileName: fileName, MimeType: mimeType, UploadId: uploadID}
+	}
+	return stream.CloseAndRecv()
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Path locates a value within a JSON document by the object keys and array
+// indices (as decimal strings) leading to it from the root, as in the original upload
+type Path []string
+
+func (p Path) String() string {
+	return strings.Join(p, ".")
+}
+
+// KeyAction is what a Rule wants done with an object property
+type KeyAction int
+
+const (
+	KeepKey KeyAction = iota
+	DropKey
+	RenameKey
+)
+
+/**
+ * Rule is a single JSON transformation.
+ * Rules see the document as it is streamed past, so properties are kept, dropped
+ * or renamed on their key (and path) alone before their value has been read,
+ * and only scalar values (json.Number, string, bool or nil) can be rewritten.
+ */
+type Rule interface {
+	// Key is called for every object property, with the path of the object it belongs to,
+	// returning what to do with it (and the new key, when renaming)
+	Key(path Path, key string) (KeyAction, string)
+	// Value is called for every scalar value, with its path, returning the value to write out
+	Value(path Path, v any) (any, error)
+}
+
+// RuleSet composes rules, applying them in order: each rule sees the key or
+// value as left by the rules before it, and a dropped property is gone for good
+type RuleSet []Rule
+
+// Check interface conformity
+var _ Rule = RuleSet{}
+
+func (rs RuleSet) Key(path Path, key string) (KeyAction, string) {
+	action := KeepKey
+	for _, rule := range rs {
+		switch a, renamed := rule.Key(path, key); a {
+		case DropKey:
+			return DropKey, ""
+		case RenameKey:
+			action, key = RenameKey, renamed
+		}
+	}
+	return action, key
+}
+
+func (rs RuleSet) Value(path Path, v any) (any, error) {
+	var err error
+	for _, rule := range rs {
+		if v, err = rule.Value(path, v); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// saturator is a Rule which can clamp values to a limit rather than fail on them,
+// keeping count of how many it has, so that can be reported once per file
+type saturator interface {
+	saturated() int
+}
+
+func (rs RuleSet) saturated() int {
+	n := 0
+	for _, rule := range rs {
+		n += saturations(rule)
+	}
+	return n
+}
+
+// how many values a rule has clamped so far, if it's one that can
+func saturations(rule Rule) int {
+	if s, ok := rule.(saturator); ok {
+		return s.saturated()
+	}
+	return 0
+}
+
+// RuleFactory builds a Rule from its JSON configuration (which may be empty)
+type RuleFactory func(config json.RawMessage) (Rule, error)
+
+var (
+	ruleFactoriesMu sync.RWMutex
+	ruleFactories   = map[string]RuleFactory{}
+)
+
+// RegisterRule makes a rule available by name; like `database/sql.Register`,
+// it panics if the name is already taken
+func RegisterRule(name string, factory RuleFactory) {
+	ruleFactoriesMu.Lock()
+	defer ruleFactoriesMu.Unlock()
+	if _, dup := ruleFactories[name]; dup {
+		panic("RegisterRule called twice for rule " + name)
+	}
+	ruleFactories[name] = factory
+}
+
+// NewRule configures an instance of the registered rule called `name`
+func NewRule(name string, config json.RawMessage) (Rule, error) {
+	ruleFactoriesMu.RLock()
+	factory, ok := ruleFactories[name]
+	ruleFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown rule '%s'", name)
+	}
+	rule, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration for rule '%s': %w", name, err)
+	}
+	return rule, nil
+}
+
+// RegisteredRules lists the names of all registered rules, sorted
+func RegisteredRules() []string {
+	ruleFactoriesMu.RLock()
+	defer ruleFactoriesMu.RUnlock()
+	names := make([]string, 0, len(ruleFactories))
+	for name := range ruleFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultRules are the modifications described in the bonus requirements
+func DefaultRules() RuleSet {
+	return RuleSet{dropVowelKeys{}, scaleEvenInts{factor: big.NewInt(1000)}}
+}
+
+func init() {
+	RegisterRule("drop-vowel-keys", newDropVowelKeys)
+	RegisterRule("scale-even-ints", newScaleEvenInts)
+	RegisterRule("drop-keys-matching", func(config json.RawMessage) (Rule, error) {
+		cfg := struct {
+			Pattern string `json:"pattern"`
+		}{}
+		if err := decodeRuleConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Pattern == "" {
+			return nil, fmt.Errorf("missing pattern")
+		}
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return dropKeysMatching{re: re}, nil
+	})
+	RegisterRule("rename-key", func(config json.RawMessage) (Rule, error) {
+		cfg := struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+			Path string `json:"path"`
+		}{}
+		if err := decodeRuleConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		if cfg.From == "" || cfg.To == "" {
+			return nil, fmt.Errorf("missing from or to")
+		}
+		return renameKey{from: cfg.From, to: cfg.To, under: parsePathPattern(cfg.Path)}, nil
+	})
+	RegisterRule("uppercase-strings", func(config json.RawMessage) (Rule, error) {
+		cfg := struct {
+			Path string `json:"path"`
+		}{}
+		if err := decodeRuleConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		return uppercaseStrings{under: parsePathPattern(cfg.Path)}, nil
+	})
+}
+
+// strictly decodes a rule's configuration, if it has any
+func decodeRuleConfig(config json.RawMessage, v any) error {
+	if len(config) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(config)))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// pathPattern matches paths at or beneath it, segment by segment,
+// where a "*" segment matches any key or array index; the empty pattern matches everything
+type pathPattern []string
+
+// parses a dot separated pattern such as "address.*.city"
+func parsePathPattern(pattern string) pathPattern {
+	if pattern == "" {
+		return nil
+	}
+	return strings.Split(pattern, ".")
+}
+
+func (pp pathPattern) matches(path Path) bool {
+	if len(path) < len(pp) {
+		return false
+	}
+	for i, segment := range pp {
+		if segment != "*" && segment != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// noValues can be embedded by rules which only act on keys
+type noValues struct{}
+
+func (noValues) Value(_ Path, v any) (any, error) { return v, nil }
+
+// noKeys can be embedded by rules which only act on values
+type noKeys struct{}
+
+func (noKeys) Key(_ Path, key string) (KeyAction, string) { return KeepKey, key }
+
+// drops keys matching a regular expression, anywhere in the document
+type dropKeysMatching struct {
+	noValues
+	re *regexp.Regexp
+}
+
+func (r dropKeysMatching) Key(_ Path, key string) (KeyAction, string) {
+	if r.re.MatchString(key) {
+		return DropKey, ""
+	}
+	return KeepKey, key
+}
+
+// renames a key in any object at or beneath a path
+type renameKey struct {
+	noValues
+	from, to string
+	under    pathPattern
+}
+
+func (r renameKey) Key(path Path, key string) (KeyAction, string) {
+	if key == r.from && r.under.matches(path) {
+		return RenameKey, r.to
+	}
+	return KeepKey, key
+}
+
+// upper-cases string values at or beneath a path
+type uppercaseStrings struct {
+	noKeys
+	under pathPattern
+}
+
+func (r uppercaseStrings) Value(path Path, v any) (any, error) {
+	if s, ok := v.(string); ok && r.under.matches(path) {
+		return strings.ToUpper(s), nil
+	}
+	return v, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// renames keys under a given path, to exercise RenameKey
+type renameRule struct {
+	noValues
+	under    string
+	from, to string
+}
+
+func (r renameRule) Key(path Path, key string) (KeyAction, string) {
+	if path.String() == r.under && key == r.from {
+		return RenameKey, r.to
+	}
+	return KeepKey, key
+}
+
+// upper-cases every string, and complains about `false`
+type shoutRule struct{ noKeys }
+
+func (shoutRule) Value(path Path, v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return strings.ToUpper(v), nil
+	case bool:
+		if !v {
+			return nil, errors.New("no negativity allowed")
+		}
+	}
+	return v, nil
+}
+
+func TestRuleSet(t *testing.T) {
+	fromRegistry := func(names ...string) Rule {
+		rs := RuleSet{}
+		for _, name := range names {
+			rule, err := NewRule(name, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs = append(rs, rule)
+		}
+		return rs
+	}
+	factorOf := func(config string) Rule {
+		rule, err := NewRule("scale-even-ints", json.RawMessage(config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rule
+	}
+
+	cases := []struct {
+		testName string
+		rule     Rule
+		input    string
+		want     string
+		wantErr  bool
+	}{
+		{"no rules", RuleSet{}, `{"apple": 2, "kiwi": [4, "x"]}`, `{"apple":2,"kiwi":[4,"x"]}`, false},
+		{"default rules", DefaultRules(), `{"apple": 2, "kiwi": [4, "x"]}`, `{"kiwi":[4000,"x"]}`, false},
+		{"registered rules", fromRegistry("drop-vowel-keys", "scale-even-ints"), `{"apple": 2, "kiwi": [4, "x"]}`, `{"kiwi":[4000,"x"]}`, false},
+		{"configured factor", factorOf(`{"factor": -2}`), `{"apple": 2, "kiwi": [3, 4]}`, `{"apple":-4,"kiwi":[3,-8]}`, false},
+		{"rename only at path", renameRule{under: "a", from: "b", to: "c"}, `{"b": 1, "a": {"b": 2}}`, `{"b":1,"a":{"c":2}}`, false},
+		{"rename then drop renamed key", RuleSet{renameRule{from: "kiwi", to: "egg"}, dropVowelKeys{}}, `{"kiwi": 1, "fig": 2}`, `{"fig":2}`, false},
+		{"drop before rename", RuleSet{dropVowelKeys{}, renameRule{from: "egg", to: "kiwi"}}, `{"egg": 1, "fig": 2}`, `{"fig":2}`, false},
+		{"rewrite values in order", RuleSet{shoutRule{}, scaleEvenInts{factor: big.NewInt(10)}}, `{"fig": "x", "n": [2, null]}`, `{"fig":"X","n":[20,null]}`, false},
+		{"failing value rule", shoutRule{}, `{"fig": true, "n": {"x": false}}`, ``, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			var got strings.Builder
+			err := transformJSON(strings.NewReader(tt.input), &got, tt.rule, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got.String())
+			}
+		})
+	}
+}
+
+func TestNewRule(t *testing.T) {
+	for _, name := range []string{"drop-vowel-keys", "scale-even-ints"} {
+		found := false
+		for _, registered := range RegisteredRules() {
+			found = found || registered == name
+		}
+		if !found {
+			t.Errorf("built-in rule '%s' is not registered", name)
+		}
+	}
+	cases := []struct {
+		testName string
+		name     string
+		config   string
+	}{
+		{"unknown rule", "drop-everything", ``},
+		{"unknown config field", "scale-even-ints", `{"factr": 10}`},
+		{"wrong config type", "scale-even-ints", `{"factor": "ten"}`},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			if _, err := NewRule(tt.name, json.RawMessage(tt.config)); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// MimePolicy decides what to make of the `mime_type` a client says it is uploading,
+// given the type detected from the first bytes of the upload itself
+type MimePolicy int
+
+const (
+	// TrustDeclared goes by the declared type, the detected type is only reported back
+	TrustDeclared MimePolicy = iota
+	// PreferDetected goes by the detected type, unless detection was inconclusive
+	PreferDetected
+	// RejectMismatch fails the upload with `INVALID_ARGUMENT` if the declared and
+	// detected types disagree (unless detection was inconclusive)
+	RejectMismatch
+)
+
+// UploaderOption configures optional behaviour of an Uploader
+type UploaderOption func(*Uploader)
+
+// WithMimePolicy sets how declared and detected mime types are reconciled (default TrustDeclared)
+func WithMimePolicy(p MimePolicy) UploaderOption {
+	return func(u *Uploader) {
+		u.mimePolicy = p
+	}
+}
+
+// same as `http.DetectContentType`, which never looks any further
+const sniffLen = 512
+
+// sniffer keeps hold of the first bytes written to it, for detecting the content type
+type sniffer struct {
+	buf []byte
+}
+
+func (s *sniffer) Write(p []byte) (int, error) {
+	if n := sniffLen - len(s.buf); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		s.buf = append(s.buf, p[:n]...)
+	}
+	return len(p), nil
+}
+
+func (s *sniffer) full() bool {
+	return len(s.buf) >= sniffLen
+}
+
+// detectContentType guesses the mime type of data from its first bytes, `complete` being
+// whether that's all there is. JSON and NDJSON are told apart from other text, CBOR is
+// recognised by its self-described tag (MessagePack has no such thing), and everything
+// else is left to `http.DetectContentType` (magic numbers and the like).
+func detectContentType(data []byte, complete bool) string {
+	if isCBOR(data) {
+		return "application/cbor"
+	}
+	if t := detectJSON(data, complete); t != "" {
+		return t
+	}
+	if t := detectConfig(data, complete); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
+
+// returns "application/json", "application/x-ndjson", or "" if data is neither, or if there's
+// no telling from the sample which of the two it is
+func detectJSON(data []byte, complete bool) string {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	// only objects & arrays, plenty of plain text files are valid JSON scalars, e.g. "42"
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return ""
+	}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	values, cutOff := 0, false
+	for {
+		var v json.RawMessage
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF && !complete {
+			// a value cut off by the end of the sample is fine, so far as it goes
+			cutOff = true
+			break
+		}
+		if err != nil {
+			return ""
+		}
+		values++
+		// NDJSON values are only ever separated by a line break
+		next := bytes.TrimLeft(trimmed[dec.InputOffset():], " \t\r")
+		if len(next) > 0 && next[0] != '\n' {
+			return ""
+		}
+	}
+	switch {
+	case values == 0 && bytes.IndexByte(trimmed, '\n') < 0:
+		// the first line didn't fit, it could be a long line of NDJSON as much as JSON
+		return ""
+	case values > 1, values == 1 && cutOff:
+		// a cut off value after a line break is another line
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// TOML table headers, e.g. [server] or [[servers]], and key/value pairs, e.g. port = 8080
+var (
+	tomlTable = regexp.MustCompile(`^\[\[?\s*[A-Za-z0-9_."'-][A-Za-z0-9_. "'-]*\]\]?\s*(#.*)?$`)
+	tomlPair  = regexp.MustCompile(`^[A-Za-z0-9_-]+(\s*\.\s*[A-Za-z0-9_-]+)*\s*=\s*\S`)
+)
+
+// returns "application/yaml" or "application/toml" for data which starts out like either,
+// or "" if it's anyone's guess; YAML only counts when it says so with a `%YAML` directive or
+// `---`, since plenty of plain text looks like "key: value", and TOML only with a table header
+// or a few key/value pairs which all parse, since plenty of plain text (and INI files, and
+// scripts) has the odd "key = value"
+func detectConfig(data []byte, complete bool) string {
+	if !complete {
+		// the last line's likely cut off
+		data = data[:bytes.LastIndexByte(data, '\n')+1]
+	}
+	tables, pairs := 0, 0
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if tables == 0 && pairs == 0 {
+			// the first line that isn't blank or a comment
+			switch {
+			case strings.HasPrefix(line, "%YAML"), line == "---", strings.HasPrefix(line, "--- "):
+				return "application/yaml"
+			case !tomlTable.MatchString(line) && !tomlPair.MatchString(line):
+				return ""
+			}
+		}
+		switch {
+		case tomlTable.MatchString(line):
+			tables++
+		case tomlPair.MatchString(line):
+			pairs++
+		}
+	}
+	if tables == 0 && pairs < 2 {
+		return ""
+	}
+	if _, err := toml.Decode(string(data), &map[string]any{}); err != nil {
+		return ""
+	}
+	return "application/toml"
+}
+
+// types which say nothing more than "some bytes" or "some text",
+// which a client is perfectly entitled to be more specific about
+func inconclusive(detected string) bool {
+	switch mediaType(detected) {
+	case "", "application/octet-stream", "text/plain":
+		return true
+	}
+	return false
+}
+
+// the type/subtype of a mime type, without any parameters, in lower case
+func mediaType(t string) string {
+	if mt, _, err := mime.ParseMediaType(t); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// JSON (including types based on it, e.g. application/vnd.api+json) and its line-delimited
+// relatives, which look the same for as long as the first line goes
+func jsonFamily(t string) bool {
+	switch mt := mediaType(t); mt {
+	case "application/json", "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return true
+	default:
+		return strings.HasSuffix(mt, "+json")
+	}
+}
+
+// whether two types are the same format, going by the Processor each is registered for, so
+// aliases (e.g. application/x-yaml and application/yaml) are; types based on JSON count as JSON
+func sameFormat(a, b string) bool {
+	a, b = format(a), format(b)
+	if a == b {
+		return true
+	}
+	pa, okA := processors[a]
+	pb, okB := processors[b]
+	return okA && okB && reflect.ValueOf(pa).Pointer() == reflect.ValueOf(pb).Pointer()
+}
+
+func format(t string) string {
+	mt := mediaType(t)
+	if strings.HasSuffix(mt, "+json") {
+		return "application/json"
+	}
+	return mt
+}
+
+// resolveContentType applies the policy to the declared and detected types, `complete` being
+// whether the type was detected from the whole upload, returning the type to go by,
+// or ok=false if the policy rejects the upload
+func (p MimePolicy) resolveContentType(declared, detected string, complete bool) (contentType string, ok bool) {
+	if inconclusive(detected) {
+		return declared, true
+	}
+	if !complete && jsonFamily(declared) && jsonFamily(detected) {
+		// only part of the upload was looked at, a guess between the two is no better than what the client says
+		return declared, true
+	}
+	switch p {
+	case PreferDetected:
+		return detected, true
+	case RejectMismatch:
+		if declared != "" && !sameFormat(declared, detected) {
+			return "", false
+		}
+		return detected, true
+	}
+	return declared, true
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// characters in a line from ndjsonLines besides the padding
+const lineOverhead = len(`{"line": ""}` + "\n")
+
+// n lines of NDJSON, each with `size` characters of padding
+func ndjsonLines(size, n int) string {
+	return strings.Repeat(`{"line": "`+strings.Repeat("x", size)+`"}`+"\n", n)
+}
+
+func TestDetectContentType(t *testing.T) {
+	cases := []struct {
+		testName string
+		data     string
+		complete bool
+		want     string
+	}{
+		{"json object", jsonBlob, true, "application/json"},
+		{"json array", `[1, 2, 3]`, true, "application/json"},
+		{"json with byte order mark", "\xef\xbb\xbf\n {\"a\": 1}", true, "application/json"},
+		{"truncated json", jsonBlob[:100], false, "application/json"},
+		{"truncated json on one line", `{"a": 1, "b": [2, 3`, false, "text/plain; charset=utf-8"},
+		{"truncated json, complete", jsonBlob[:100], true, "text/plain; charset=utf-8"},
+		{"ndjson", "{\"a\": 1}\n{\"b\": 2}\n", true, "application/x-ndjson"},
+		{"ndjson with crlf", "{\"a\": 1}\r\n[2]\r\n", true, "application/x-ndjson"},
+		{"truncated ndjson", "{\"a\": 1}\n{\"b\": 2}\n{\"c\": ", false, "application/x-ndjson"},
+		{"ndjson with the second line cut off", ndjsonLines(300, 3), true, "application/x-ndjson"},
+		{"ndjson with lines longer than the sample", ndjsonLines(600, 3), true, "text/plain; charset=utf-8"},
+		{"ndjson with the first line filling the sample", ndjsonLines(sniffLen-lineOverhead, 3), true, "application/json"},
+		{"values on the same line", `{"a": 1} {"b": 2}`, true, "text/plain; charset=utf-8"},
+		{"json scalar is just text", `42`, true, "text/plain; charset=utf-8"},
+		{"looks like json but isn't", `{apple: 1}`, true, "text/plain; charset=utf-8"},
+		{"plain text", haikuString, true, "text/plain; charset=utf-8"},
+		{"yaml", yamlBlob, true, "application/yaml"},
+		{"yaml directive", "%YAML 1.2\n---\nfig: 2\n", true, "application/yaml"},
+		{"yaml without a start marker", "fig: 2\n", true, "text/plain; charset=utf-8"},
+		{"toml", tomlBlob, true, "application/toml"},
+		{"toml table", "\n[servers.alpha]\nip = \"10.0.0.1\"\n", true, "application/toml"},
+		{"toml dotted keys", "fruit.name = \"kiwi\"\nfruit.count = 2\n", true, "application/toml"},
+		{"toml cut off", tomlBlob[:60], false, "application/toml"},
+		{"a lone toml pair", "fruit.name = \"kiwi\"\n", true, "text/plain; charset=utf-8"},
+		{"plain text with an equals sign", "Kiwi = a small brown fruit\nNot to be confused with the bird.\n", true, "text/plain; charset=utf-8"},
+		{"ini", "; fruit\n[kiwi]\nname = kiwi\ncolour = brown\n", true, "text/plain; charset=utf-8"},
+		{"ini without comments", "[kiwi]\nname = kiwi\ncolour = brown\n", true, "text/plain; charset=utf-8"},
+		{"python", "count = 2\nprint(count)\n", true, "text/plain; charset=utf-8"},
+		{"markdown", "# Title\n\nSome [link](https://example.com) = text\n", true, "text/plain; charset=utf-8"},
+		{"html", "<!DOCTYPE html><html></html>", true, "text/html; charset=utf-8"},
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", true, "image/png"},
+		{"gzip", "\x1f\x8b\x08\x00\x00\x00\x00\x00", true, "application/x-gzip"},
+		{"binary", "\x00\x01\x02\x03", true, "application/octet-stream"},
+		{"empty", "", true, "text/plain; charset=utf-8"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			data, complete := tt.data, tt.complete
+			if len(data) > sniffLen {
+				data, complete = data[:sniffLen], false
+			}
+			if got := detectContentType([]byte(data), complete); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUploaderService_UploadFile_MimePolicy(t *testing.T) {
+	const png = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	const csv = "apple,name\n2,kiwi\n"
+	cases := []struct {
+		testName     string
+		policy       MimePolicy
+		mimeType     string
+		data         string
+		code         codes.Code
+		detected     string
+		wantModified bool
+	}{
+		{"trust declared json", TrustDeclared, "application/json", jsonBlob, codes.OK, "application/json", true},
+		{"trust declared text", TrustDeclared, "text/plain", jsonBlob, codes.OK, "application/json", false},
+		{"prefer detected, undeclared", PreferDetected, "", jsonBlob, codes.OK, "application/json", true},
+		{"prefer detected over declared", PreferDetected, "application/octet-stream", jsonBlob, codes.OK, "application/json", true},
+		{"prefer detected, not actually json", PreferDetected, "application/json", png, codes.OK, "image/png", false},
+		{"prefer detected, inconclusive", PreferDetected, "text/csv", csv, codes.OK, "text/plain; charset=utf-8", true},
+		{"prefer detected, ndjson cut off", PreferDetected, "application/x-ndjson", ndjsonLines(300, 3), codes.OK, "application/x-ndjson", true},
+		{"prefer detected, long ndjson lines", PreferDetected, "application/x-ndjson", ndjsonLines(600, 3), codes.OK, "text/plain; charset=utf-8", true},
+		{"prefer detected, ndjson line fills the sample", PreferDetected, "application/x-ndjson", ndjsonLines(sniffLen-lineOverhead, 3), codes.OK, "application/json", true},
+		{"reject mismatch, matching", RejectMismatch, "application/json; charset=utf-8", jsonBlob, codes.OK, "application/json", true},
+		{"reject mismatch, undeclared", RejectMismatch, "", jsonBlob, codes.OK, "application/json", true},
+		{"reject mismatch, inconclusive", RejectMismatch, "text/csv", csv, codes.OK, "text/plain; charset=utf-8", true},
+		{"reject mismatch, ndjson line fills the sample", RejectMismatch, "application/x-ndjson", ndjsonLines(sniffLen-lineOverhead, 3), codes.OK, "application/json", true},
+		{"reject mismatch", RejectMismatch, "text/plain", jsonBlob, codes.InvalidArgument, "", false},
+		{"reject mismatch, short file", RejectMismatch, "image/png", `{"a": 1}`, codes.InvalidArgument, "", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			buf := NewBufferWriter()
+			uploadSvc := NewCustomUploader(buf, WithMimePolicy(tt.policy))
+			conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+				uploadpb.RegisterUploaderServer(srv, uploadSvc)
+			})
+			client := uploadpb.NewUploaderClient(conn)
+
+			resp, err := sendDataInChunksToServer(t, client, tt.data, "upload", tt.mimeType)
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected %s, got: %v", tt.code, err)
+			}
+			if err != nil {
+				return
+			}
+			if resp.GetMimeType() != tt.mimeType {
+				t.Errorf("expected declared mime type %q, got %q", tt.mimeType, resp.GetMimeType())
+			}
+			if resp.GetDetectedMimeType() != tt.detected {
+				t.Errorf("expected detected mime type %q, got %q", tt.detected, resp.GetDetectedMimeType())
+			}
+			if _, found := buf.m["modified_upload"]; found != tt.wantModified {
+				t.Errorf("expected modified copy %t, got %t", tt.wantModified, found)
+			}
+		})
+	}
+}
+
+// aliases of the same format aren't a mismatch, nor are types based on JSON
+func TestMimePolicy_RejectMismatch_Aliases(t *testing.T) {
+	cases := []struct {
+		declared, detected string
+		ok                 bool
+	}{
+		{"application/x-yaml", "application/yaml", true},
+		{"application/jsonl", "application/x-ndjson", true},
+		{"application/vnd.api+json", "application/json", true},
+		{"text/x-toml; charset=utf-8", "application/toml", true},
+		{"application/json", "application/x-ndjson", false},
+		{"application/yaml", "application/toml", false},
+		{"text/plain", "application/json", false},
+	}
+	for _, tt := range cases {
+		got, ok := RejectMismatch.resolveContentType(tt.declared, tt.detected, true)
+		if ok != tt.ok || (ok && got != tt.detected) {
+			t.Errorf("declared %q, detected %q: expected %t, got %q, %t", tt.declared, tt.detected, tt.ok, got, ok)
+		}
+	}
+}
+
+// resuming an upload still sniffs the bytes received by the first stream
+func TestUploaderService_ResumeUpload_MimePolicy(t *testing.T) {
+	buf := NewBufferWriter()
+	uploadSvc := NewCustomUploader(buf, WithMimePolicy(RejectMismatch))
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	buf.m[partialPrefix+"abc"] = []byte(jsonBlob[:sniffLen+10])
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stream, err := client.UploadFile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&uploadpb.UploadRequest{
+		FileName: "upload",
+		MimeType: "text/csv",
+		UploadId: "abc",
+		Offset:   sniffLen + 10,
+		Chunk:    []byte(jsonBlob[sniffLen+10:]),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.CloseAndRecv()
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "application/json") {
+		t.Fatalf("expected %s, got: %v", codes.InvalidArgument, err)
+	}
+	if offset := queryOffset(t, client, "abc"); offset != 0 {
+		t.Errorf("expected offset 0 after rejection, got %d", offset)
+	}
+}
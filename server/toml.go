@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+/**
+ * ProcessTOML is ProcessJSON for TOML documents.
+ * The rules see the same tree as they would for JSON: tables as objects, arrays as arrays,
+ * and integers & floats as json.Number. Dates and times are left alone.
+ *
+ * Unlike JSON and YAML, the whole document is decoded into memory, and the modified copy
+ * is written out with its keys sorted and without comments; TOML is for config files,
+ * which are small enough, and read by programs, which don't care.
+ */
+func ProcessTOML(filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error) {
+	err := processFile(filename, x, func(r io.Reader, w io.Writer) error {
+		return transformTOML(r, w, spec.rules())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write modified TOML data to file: %w", err)
+	}
+	return &ProcessReport{}, nil
+}
+
+func transformTOML(r io.Reader, w io.Writer, rule Rule) error {
+	var doc map[string]any
+	if _, err := toml.NewDecoder(r).Decode(&doc); err != nil {
+		return fmt.Errorf("%w toml data: %w", ErrInvalidData, err)
+	}
+	modified, err := transformTOMLValue(doc, nil, rule)
+	if err != nil {
+		return err
+	}
+	return toml.NewEncoder(w).Encode(modified)
+}
+
+func transformTOMLValue(v any, path Path, rule Rule) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		return transformTOMLTable(v, path, rule)
+	case []map[string]any:
+		// array of tables
+		for i, table := range v {
+			if _, err := transformTOMLTable(table, append(path, strconv.Itoa(i)), rule); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case []any:
+		for i, elem := range v {
+			var err error
+			if v[i], err = transformTOMLValue(elem, append(path, strconv.Itoa(i)), rule); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}
+	before := v
+	// numbers as the rules expect to see them
+	switch n := v.(type) {
+	case int64:
+		before = json.Number(strconv.FormatInt(n, 10))
+	case float64:
+		if !math.IsInf(n, 0) && !math.IsNaN(n) {
+			before = floatNumber(n, 64)
+		}
+	}
+	after, err := rule.Value(path, before)
+	if err != nil {
+		return nil, ruleFailed(path, err)
+	}
+	if sameScalar(before, after) {
+		return v, nil
+	}
+	if after, err = tomlScalar(v, after); err != nil {
+		return nil, ruleFailed(path, err)
+	}
+	return after, nil
+}
+
+// modifies the table in place, dropping & renaming keys
+func transformTOMLTable(table map[string]any, path Path, rule Rule) (map[string]any, error) {
+	// renamed keys are only put back once every key has been seen,
+	// so they can't be mistaken for one of the original keys
+	renamed := map[string]any{}
+	for key, v := range table {
+		action, newKey := rule.Key(path, key)
+		if action == DropKey {
+			delete(table, key)
+			continue
+		}
+		modified, err := transformTOMLValue(v, append(path, key), rule)
+		if err != nil {
+			return nil, err
+		}
+		if action == RenameKey {
+			delete(table, key)
+			renamed[newKey] = modified
+			continue
+		}
+		table[key] = modified
+	}
+	for key, v := range renamed {
+		table[key] = v
+	}
+	return table, nil
+}
+
+// converts a value from a rule back into something TOML can encode,
+// keeping integers as integers and floats as floats
+func tomlScalar(original, v any) (any, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		if v == nil {
+			return nil, errors.New("toml has no null")
+		}
+		return v, nil
+	}
+	if _, wasFloat := original.(float64); !wasFloat {
+		i, err := strconv.ParseInt(n.String(), 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("%s is out of range for a toml integer", n)
+		}
+		if err == nil {
+			return i, nil
+		}
+	}
+	f, err := strconv.ParseFloat(n.String(), 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s is out of range for a toml float", n)
+	}
+	return f, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransformTOML(t *testing.T) {
+	cases := []struct {
+		testName string
+		spec     string
+		input    string
+		want     string
+		err      string
+	}{
+		{"array of tables", "", "[[fruit]]\nfig = 2\n\n[[fruit]]\nkiwi = [4, 5.0]\n", "[[fruit]]\n  fig = 2000\n\n[[fruit]]\n  kiwi = [4000, 5.0]\n", ""},
+		{"integral floats aren't integers", "", "fig = 4.0\nkiwi = 4e6\n", "fig = 4.0\nkiwi = 4000000.0\n", ""},
+		{"floats stay floats", `{"rules": [{"rule": "scale-even-ints", "integral_floats": true}]}`, "fig = 2.0\n", "fig = 2000.0\n", ""},
+		{"rename key", `{"rules": [{"rule": "rename-key", "from": "fig", "to": "kiwi"}, {"rule": "rename-key", "from": "pear", "to": "fig"}]}`, "fig = 1\npear = 2\n", "fig = 2\nkiwi = 1\n", ""},
+		{"out of range", "", "fig = 9223372036854775806\n", "", "out of range for a toml integer"},
+		{"not toml", "", "fig = \n", "", "not valid toml data"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			spec := &TransformSpec{Rules: DefaultRules()}
+			if tt.spec != "" {
+				var err error
+				if spec, err = ParseTransformSpec(tt.spec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var out strings.Builder
+			err := transformTOML(strings.NewReader(tt.input), &out, spec.rules())
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got: %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, out.String())
+			}
+		})
+	}
+}
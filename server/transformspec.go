@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/**
+ * TransformSpec is a client's choice of post-processing for its upload,
+ * sent as JSON in the `transform_spec` of the first UploadRequest, e.g.
+ *
+ *	{"rules": [
+ *		{"rule": "drop-keys-matching", "pattern": "^_"},
+ *		{"rule": "scale-even-ints", "factor": 10},
+ *		{"rule": "uppercase-strings", "path": "address.*"}
+ *	], "preserve_formatting": true}
+ *
+ * Each rule names a registered Rule, every other field is that rule's configuration.
+ * With `preserve_formatting`, the modified copy keeps the whitespace of the original.
+ * With `"output": "json"`, the modified copy of a CBOR or MessagePack upload is written
+ * out as JSON instead of in the same format (as NDJSON, if there's more than one value).
+ */
+type TransformSpec struct {
+	Rules              RuleSet
+	PreserveFormatting bool
+	Output             string
+}
+
+// ParseTransformSpec validates a transform spec, returning the rules (and options) it describes
+func ParseTransformSpec(spec string) (*TransformSpec, error) {
+	var ts struct {
+		Rules              []map[string]json.RawMessage `json:"rules"`
+		PreserveFormatting bool                         `json:"preserve_formatting"`
+		Output             string                       `json:"output"`
+	}
+	decoder := json.NewDecoder(strings.NewReader(spec))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&ts); err != nil {
+		return nil, fmt.Errorf("not a valid transform spec: %w", err)
+	}
+	// the one object, and nothing after it
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		return nil, fmt.Errorf("not a valid transform spec: unexpected data after the spec")
+	}
+	if ts.Rules == nil {
+		return nil, fmt.Errorf("not a valid transform spec: missing rules")
+	}
+	if ts.Output != "" && ts.Output != "json" {
+		return nil, fmt.Errorf("not a valid transform spec: unknown output '%s'", ts.Output)
+	}
+	rules := RuleSet{}
+	for i, fields := range ts.Rules {
+		var name string
+		if err := json.Unmarshal(fields["rule"], &name); err != nil || name == "" {
+			return nil, fmt.Errorf("rules[%d]: missing rule name", i)
+		}
+		// everything else is configuration for the rule
+		delete(fields, "rule")
+		var config json.RawMessage
+		if len(fields) > 0 {
+			config, _ = json.Marshal(fields)
+		}
+		rule, err := NewRule(name, config)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return &TransformSpec{Rules: rules, PreserveFormatting: ts.PreserveFormatting, Output: ts.Output}, nil
+}
+
+// the rules to apply, which are the DefaultRules without a spec
+func (ts *TransformSpec) rules() Rule {
+	if ts == nil {
+		return DefaultRules()
+	}
+	return ts.Rules
+}
+
+// whether the modified copy is to be written out as JSON, whatever the format of the upload
+func (ts *TransformSpec) outputJSON() bool {
+	return ts != nil && ts.Output == "json"
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseTransformSpec(t *testing.T) {
+	const input = `{"_id": 7, "name": "fig", "kiwi": 4, "address": {"city": "Auckland", "zip": "ab12"}, "tags": ["x"]}`
+	cases := []struct {
+		testName string
+		spec     string
+		want     string
+	}{
+		{"no rules", `{"rules": []}`, `{"_id":7,"name":"fig","kiwi":4,"address":{"city":"Auckland","zip":"ab12"},"tags":["x"]}`},
+		{"built-in rules", `{"rules": [{"rule": "drop-vowel-keys"}, {"rule": "scale-even-ints"}]}`, `{"_id":7,"name":"fig","kiwi":4000,"tags":["x"]}`},
+		{"drop keys matching", `{"rules": [{"rule": "drop-keys-matching", "pattern": "^(_|t)"}]}`, `{"name":"fig","kiwi":4,"address":{"city":"Auckland","zip":"ab12"}}`},
+		{"scale by factor", `{"rules": [{"rule": "scale-even-ints", "factor": 3}]}`, `{"_id":7,"name":"fig","kiwi":12,"address":{"city":"Auckland","zip":"ab12"},"tags":["x"]}`},
+		{"uppercase under path", `{"rules": [{"rule": "uppercase-strings", "path": "address"}]}`, `{"_id":7,"name":"fig","kiwi":4,"address":{"city":"AUCKLAND","zip":"AB12"},"tags":["x"]}`},
+		{"uppercase with wildcard", `{"rules": [{"rule": "uppercase-strings", "path": "*.0"}]}`, `{"_id":7,"name":"fig","kiwi":4,"address":{"city":"Auckland","zip":"ab12"},"tags":["X"]}`},
+		{"uppercase everything", `{"rules": [{"rule": "uppercase-strings"}]}`, `{"_id":7,"name":"FIG","kiwi":4,"address":{"city":"AUCKLAND","zip":"AB12"},"tags":["X"]}`},
+		{"preserve formatting", `{"rules": [{"rule": "drop-vowel-keys"}], "preserve_formatting": true}`, `{"_id": 7, "name": "fig", "kiwi": 4, "tags": ["x"]}`},
+		{"rename key", `{"rules": [{"rule": "rename-key", "from": "zip", "to": "postcode", "path": "address"}]}`, `{"_id":7,"name":"fig","kiwi":4,"address":{"city":"Auckland","postcode":"ab12"},"tags":["x"]}`},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			spec, err := ParseTransformSpec(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got strings.Builder
+			if err := transformJSON(strings.NewReader(input), &got, spec.Rules, spec.PreserveFormatting); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got.String())
+			}
+		})
+	}
+}
+
+func TestParseTransformSpec_Invalid(t *testing.T) {
+	cases := []struct {
+		testName string
+		spec     string
+	}{
+		{"not json", `rules: [drop-vowel-keys]`},
+		{"missing rules", `{}`},
+		{"trailing data", `{"rules": []} junk`},
+		{"two specs", `{"rules": []} {"rules": []}`},
+		{"unknown top-level field", `{"rules": [], "preserve": true}`},
+		{"rule without name", `{"rules": [{"pattern": "^_"}]}`},
+		{"unknown rule", `{"rules": [{"rule": "drop-everything"}]}`},
+		{"bad regular expression", `{"rules": [{"rule": "drop-keys-matching", "pattern": "(["}]}`},
+		{"missing regular expression", `{"rules": [{"rule": "drop-keys-matching"}]}`},
+		{"unknown rule config", `{"rules": [{"rule": "uppercase-strings", "paht": "a"}]}`},
+		{"incomplete rename", `{"rules": [{"rule": "rename-key", "from": "zip"}]}`},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			if _, err := ParseTransformSpec(tt.spec); err == nil {
+				t.Errorf("expected error for %s", tt.spec)
+			}
+		})
+	}
+}
+
+func TestUploaderService_UploadFile_TransformSpec(t *testing.T) {
+	buf := NewBufferWriter()
+	uploadSvc := NewCustomUploader(buf)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+	const data = `{"apple": 2, "name": "kiwi"}`
+
+	cases := []struct {
+		testName string
+		mimeType string
+		spec     string
+		code     codes.Code
+		want     string // modified copy, if any
+	}{
+		{"default rules for json", "application/json", "", codes.OK, `{"name":"kiwi"}`},
+		{"no processing for text", "text/plain", "", codes.OK, ""},
+		{"spec applies to json of any type", "text/plain", `{"rules": [{"rule": "uppercase-strings"}]}`, codes.OK, `{"apple":2,"name":"KIWI"}`},
+		{"spec replaces default rules", "application/json", `{"rules": [{"rule": "scale-even-ints", "factor": 5}]}`, codes.OK, `{"apple":10,"name":"kiwi"}`},
+		{"empty spec turns processing off", "application/json", `{"rules": []}`, codes.OK, ""},
+		{"invalid spec", "application/json", `{"rules": [{"rule": "nope"}]}`, codes.InvalidArgument, ""},
+		{"undeclared", "", `{"rules": [{"rule": "uppercase-strings"}]}`, codes.OK, `{"apple":2,"name":"KIWI"}`},
+	}
+	for i, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			fn := "file" + string(rune('a'+i))
+			_, err := sendRequestsToServer(t, client, []*uploadpb.UploadRequest{{
+				FileName:      fn,
+				MimeType:      tt.mimeType,
+				TransformSpec: tt.spec,
+				Chunk:         []byte(data),
+			}})
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected %s, got: %v", tt.code, err)
+			}
+			got, found := buf.m["modified_"+fn]
+			if found != (tt.want != "") {
+				t.Fatalf("expected modified copy: %t, found: %t", tt.want != "", found)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+			// rejected before anything was written
+			if _, found := buf.m[fn]; found != (tt.code == codes.OK) {
+				t.Errorf("expected upload to be stored: %t, found: %t", tt.code == codes.OK, found)
+			}
+		})
+	}
+}
+
+func TestProcessorFor(t *testing.T) {
+	spec := &TransformSpec{Rules: DefaultRules()}
+	cases := []struct {
+		contentType, detected string
+		spec                  *TransformSpec
+		wantNone              bool
+		wantErr               error
+	}{
+		{"application/json", "application/json", nil, false, nil},
+		{"application/json", "application/json", &TransformSpec{Rules: RuleSet{}}, true, nil},
+		{"text/plain", "application/json", nil, true, nil},
+		{"text/plain", "application/json", spec, false, nil},
+		{"application/vnd.api+json", "text/plain; charset=utf-8", spec, false, nil},
+		{"", "text/plain; charset=utf-8", spec, false, nil},
+		{"", "", spec, false, nil},
+		{"image/png", "image/png", nil, true, nil},
+		{"image/png", "image/png", spec, true, ErrNoProcessor},
+		{"application/octet-stream", "application/octet-stream", spec, true, ErrNoProcessor},
+		{"", "image/png", spec, true, ErrNoProcessor},
+	}
+	for _, tt := range cases {
+		process, err := processorFor(tt.contentType, tt.detected, tt.spec)
+		if !errors.Is(err, tt.wantErr) || (process == nil) != tt.wantNone {
+			t.Errorf("%q (detected %q): expected processor %t and error %v, got %t and %v",
+				tt.contentType, tt.detected, !tt.wantNone, tt.wantErr, process != nil, err)
+		}
+	}
+}
+
+// a spec for an upload of a type with no processor is rejected, rather than failing as invalid JSON
+func TestUploaderService_UploadFile_NoProcessor(t *testing.T) {
+	buf := NewBufferWriter()
+	uploadSvc := NewCustomUploader(buf, WithMimePolicy(PreferDetected))
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	for fn, data := range map[string]string{
+		"image.png": "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"blob.bin":  strings.Repeat("\x00\x01\x02\x03", 200),
+	} {
+		_, err := sendRequestsToServer(t, client, []*uploadpb.UploadRequest{{
+			FileName:      fn,
+			MimeType:      "application/octet-stream",
+			TransformSpec: `{"rules": [{"rule": "uppercase-strings"}]}`,
+			Chunk:         []byte(data),
+		}})
+		checkDetails(t, err, wantDetails{code: codes.InvalidArgument, field: "transform_spec"})
+		if !strings.Contains(err.Error(), "no processor") {
+			t.Errorf("expected the error to say there's no processor, got: %v", err)
+		}
+		if _, found := buf.m[fn]; found {
+			t.Errorf("expected '%s' not to be stored", fn)
+		}
+	}
+}
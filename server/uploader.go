@@ -1,10 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	uploadpb "github.com/benjamin-rood/x-grpc/proto"
 	"google.golang.org/grpc/codes"
@@ -20,11 +32,21 @@ type Uploader struct {
 	uploadpb.UnsafeUploaderServer
 
 	// embedded type that does all the other stuff
-	io_thingee OpenWriteCloserLoader
+	// (hands out a fresh writer per upload, so concurrent streams never share one)
+	io_thingee WriterFactory
 	// it's an 80s-90s kiwi childhood reference... why yes I am spending too much time on this,
 	// and I can't think of a sensible thing to call this and I'm going nuts, sorry
 	// see: https://en.wikipedia.org/wiki/Thingee
 	// and: https://www.youtube.com/watch?v=GC3LK1nx-DU
+
+	// upload IDs of resumable uploads currently being streamed
+	inProgress sync.Map
+	// what to make of the declared mime type vs the detected one
+	mimePolicy MimePolicy
+	// post-processing done in the background, if not nil
+	jobs *jobQueue
+	// stops collecting garbage in the background, if not nil
+	stopGC func()
 }
 
 // Check interface conformity
@@ -38,46 +60,228 @@ type OpenWriteCloserLoader interface {
 	//		-- we can implement an in-memory version which writes the upload to a bytes.Buffer
 	//		& confirm what UploadFile writes without needing to write to disk (avoid whenever possible)
 	//		and then having to clean that up afterwards as part of a test
+	//
+	// nothing written is stored under the file name until Close, so a failed upload never
+	// leaves a half-written file in place of what was there before
 	Open(string) error
 	io.Writer
 	io.Closer
-	Load(string) ([]byte, error)
+	// Abort throws away whatever was written since Open, leaving any file already stored
+	// under the name as it was (a resumed upload keeps what it has, to be resumed again)
+	Abort() error
+	// Load returns a reader over a stored file, so callers can stream it
+	// back out without holding the whole thing in memory
+	Load(string) (io.ReadCloser, error)
+}
+
+// WriterFactory creates a new OpenWriteCloserLoader session for each UploadFile stream.
+// Sessions opened from the same factory share the same underlying storage, but each
+// keeps track of its own open file, so concurrent uploads don't clobber each other.
+type WriterFactory interface {
+	NewWriter() OpenWriteCloserLoader
 }
 
-func NewCustomUploader(writer OpenWriteCloserLoader) *Uploader {
-	return &Uploader{io_thingee: writer}
+// Locator is implemented by writers that can say where a file is stored, e.g. its path on disk
+type Locator interface {
+	Locate(filename string) string
+}
+
+// the name of the copy of a file written by post-processing
+func modifiedName(filename string) string {
+	return "modified_" + filename
+}
+
+// reads the entirety of a stored file into memory
+func loadAll(x OpenWriteCloserLoader, filename string) ([]byte, error) {
+	r, err := x.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func NewCustomUploader(writers WriterFactory, opts ...UploaderOption) *Uploader {
+	u := &Uploader{io_thingee: writers}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
 }
 
-const receivedFilesDir = "./received_files"
+const (
+	receivedFilesDir = "./received_files"
+	// where post-processing jobs are kept, within receivedFilesDir
+	jobsDirName = ".jobs"
+)
 
 func DefaultUploader() *Uploader {
 	// create folder where uploaded files will go
-	if err := os.MkdirAll(receivedFilesDir, os.ModePerm); err != nil {
+	writer, err := newDiskWriter(receivedFilesDir)
+	if err != nil {
+		panic(err)
+	}
+	return defaultUploader(writer)
+}
+
+// DefaultVersionedUploader is the DefaultUploader, except it keeps every version of a file
+// as it's replaced (or the latest `retain` of them, if that's not 0)
+func DefaultVersionedUploader(retain int) *Uploader {
+	writer, err := newVersionedWriter(receivedFilesDir, retain)
+	if err != nil {
+		panic(err)
+	}
+	return defaultUploader(writer)
+}
+
+// DefaultDedupingUploader is the DefaultUploader, except files are stored by their contents,
+// so the same contents uploaded more than once are only stored the once
+func DefaultDedupingUploader() *Uploader {
+	writer, err := newCASWriter(receivedFilesDir)
+	if err != nil {
+		panic(err)
+	}
+	// blobs are removed as they're unreferenced, this is for any that couldn't be
+	return defaultUploader(writer, WithGarbageCollection(gcInterval))
+}
+
+// how often DefaultDedupingUploader collects garbage
+const gcInterval = time.Hour
+
+func defaultUploader(writer WriterFactory, opts ...UploaderOption) *Uploader {
+	jobsDir := filepath.Join(receivedFilesDir, jobsDirName)
+	if err := os.MkdirAll(jobsDir, os.ModePerm); err != nil {
 		panic(err)
 	}
-	return &Uploader{io_thingee: &diskWriter{writeDirPath: receivedFilesDir}}
+	return NewCustomUploader(writer, append([]UploaderOption{
+		// the client's word for what it's sending only counts if we can't tell for ourselves
+		WithMimePolicy(PreferDetected),
+		// one job per CPU, processing is CPU bound
+		WithJobQueue(&diskJobStore{dir: jobsDir}, runtime.NumCPU()),
+	}, opts...)...)
+}
+
+// for a complete upload which couldn't be put in place
+func storeError(filename string, err error) error {
+	switch {
+	case errors.Is(err, ErrFileExists):
+		return fileExistsError(filename)
+	case errors.Is(err, ErrUnsafeFileName):
+		return fileNameError(filename, err)
+	}
+	return storageError("save file", filename, err)
 }
 
 func (u *Uploader) UploadFile(stream uploadpb.Uploader_UploadFileServer) error {
-	close := func() {
-		if err := u.io_thingee.Close(); err != nil {
-			log.Fatalf("could not close file: %s", err)
+	// every stream gets its own writer session
+	w := u.io_thingee.NewWriter()
+	// whatever goes wrong, nothing is stored under the file name until the upload is complete
+	// (does nothing once it's been closed)
+	abort := func() {
+		if err := w.Abort(); err != nil {
+			log.Printf("could not abort file: %s", err)
 		}
-		log.Println("closed")
 	}
-	defer close()
+	defer abort()
 
 	// grab the initial message segment to get the `file_name` & `meta_data` arguments
 	req, err := stream.Recv()
+	start := time.Now()
 	contentType := req.GetMimeType()
 	log.Println("Content-Type:", contentType)
 	fn := strings.TrimSpace(req.GetFileName())
 	// reject if no `file_name` argument provided, make use of it
 	if fn == "" {
-		return status.Errorf(codes.InvalidArgument, "missing file_name arg")
+		return withDetails(status.Errorf(codes.InvalidArgument, "missing file_name arg"), fieldViolation("file_name", "a file name is required"))
+	}
+	if err := checkUploadName(fn); err != nil {
+		return fileNameError(fn, err)
+	}
+	var spec *TransformSpec
+	rawSpec := req.GetTransformSpec()
+	if rawSpec != "" {
+		var specErr error
+		if spec, specErr = ParseTransformSpec(rawSpec); specErr != nil {
+			return withDetails(status.Errorf(codes.InvalidArgument, "invalid transform_spec: %s", specErr), fieldViolation("transform_spec", specErr.Error()))
+		}
+	}
+	policy, ok := conflictPolicies[req.GetConflictPolicy()]
+	if !ok {
+		return withDetails(status.Errorf(codes.InvalidArgument, "unknown conflict_policy %d", req.GetConflictPolicy()),
+			fieldViolation("conflict_policy", "not a known policy"))
+	}
+	if policy != Overwrite {
+		resolver, ok := w.(ConflictResolver)
+		if !ok {
+			return withDetails(status.Errorf(codes.Unimplemented, "conflict_policy %s is not supported by this server", req.GetConflictPolicy()),
+				fieldViolation("conflict_policy", "only overwriting is supported"))
+		}
+		resolver.SetConflictPolicy(policy)
+	}
+	if policy == FailOnConflict {
+		// no need to wait for the whole upload to find out (it's checked again once it's all here, mind)
+		if r, err := w.Load(fn); err == nil {
+			r.Close()
+			return fileExistsError(fn)
+		}
 	}
-	if err := u.io_thingee.Open(fn); err != nil {
-		return status.Errorf(codes.Internal, "failed to open file: %s", err)
+	uploadID := req.GetUploadId()
+	var size uint64
+	// digest of everything stored, to check against what the client says it sent
+	digest := sha256.New()
+	// the first bytes of the file, to see what it really is
+	sniff := &sniffer{}
+	declared, detected := contentType, ""
+	sniffed := false
+	detect := func(complete bool) error {
+		sniffed = true
+		detected = detectContentType(sniff.buf, complete)
+		log.Println("Detected Content-Type:", detected)
+		reject := func(err error) error {
+			if uploadID != "" {
+				// it's not going to be any different next time
+				abort()
+				if err := w.(Resumer).Discard(uploadID); err != nil {
+					log.Printf("failed to discard partial upload '%s': %s", uploadID, err)
+				}
+			}
+			return err
+		}
+		var ok bool
+		if contentType, ok = u.mimePolicy.resolveContentType(declared, detected, complete); !ok {
+			return reject(withDetails(status.Errorf(codes.InvalidArgument, "declared mime_type '%s' does not match detected '%s'", declared, detected),
+				fieldViolation("mime_type", fmt.Sprintf("content looks like '%s'", detected)),
+				errorInfo(ReasonMimeTypeMismatch, map[string]string{"declared": declared, "detected": detected})))
+		}
+		// no sense storing an upload the spec can't be applied to
+		if _, err := processorFor(contentType, detected, spec); err != nil {
+			return reject(withDetails(status.Errorf(codes.InvalidArgument, "invalid transform_spec: %s", err),
+				fieldViolation("transform_spec", err.Error())))
+		}
+		return nil
+	}
+	if uploadID == "" {
+		if err := w.Open(fn); errors.Is(err, ErrUnsafeFileName) {
+			return fileNameError(fn, err)
+		} else if err != nil {
+			return storageError("open file", fn, err)
+		}
+	} else {
+		// continue (or start) a resumable upload from the requested offset
+		release, err := u.resumeUpload(w, uploadID, req.GetOffset())
+		if err != nil {
+			return err
+		}
+		// make sure the partial upload is persisted before another stream can pick it up
+		defer func() {
+			abort()
+			release()
+		}()
+		// catch the digest up with the bytes received by previous streams
+		if err := digestPartial(w.(Resumer), uploadID, req.GetOffset(), io.MultiWriter(digest, sniff)); err != nil {
+			return storageError("read partial upload", uploadID, err)
+		}
+		size = req.GetOffset()
 	}
 
 	// implement handling of stream upload from a client in the following way:
@@ -86,31 +290,176 @@ func (u *Uploader) UploadFile(stream uploadpb.Uploader_UploadFileServer) error {
 	//   - unless EOF, read the bytes chunk from the UploadRequest message and write DIRECTLY to disk
 	// 	 - get next segment
 	// - once we have received all the data, try to process the data as json
-	var size uint32
+	var wantDigest []byte
 	for {
 		if err == io.EOF {
-			// finish writing received bytes
-			close()
-			if contentType == "application/json" {
+			if !sniffed {
+				// the whole file (probably) fits in the sample
+				if err := detect(!sniff.full()); err != nil {
+					return err
+				}
+			}
+			gotDigest := digest.Sum(nil)
+			if wantDigest != nil && !bytes.Equal(gotDigest, wantDigest) {
+				abort()
+				if uploadID != "" {
+					// no use resuming from here, the client will have to start over
+					if err := w.(Resumer).Discard(uploadID); err != nil {
+						log.Printf("failed to discard partial upload '%s': %s", uploadID, err)
+					}
+				}
+				return withDetails(status.Errorf(codes.DataLoss, "sha256 of received data %x does not match %x", gotDigest, wantDigest),
+					errorInfo(ReasonChecksumMismatch, map[string]string{"checksum": "sha256", "expected": hex.EncodeToString(wantDigest), "actual": hex.EncodeToString(gotDigest)}))
+			}
+			// finish writing received bytes, and put the file in place
+			if err := w.Close(); err != nil {
+				return storeError(fn, err)
+			}
+			if uploadID != "" {
+				// all done, move the partial upload to its final file name
+				if err := w.(Resumer).Commit(uploadID, fn); err != nil {
+					return storeError(fn, err)
+				}
+			}
+			// the conflict policy may have put it somewhere else
+			var kept string
+			if resolver, ok := w.(ConflictResolver); ok {
+				if stored, k := resolver.Stored(); stored != "" {
+					fn, kept = stored, k
+				}
+			}
+			resp := &uploadpb.UploadResponse{
+				FileName:         fn,
+				MimeType:         declared,
+				Size:             size,
+				Sha256:           gotDigest,
+				DetectedMimeType: detected,
+				StoredPath:       fn,
+				UploadMicros:     uint64(time.Since(start).Microseconds()),
+				Processing:       &uploadpb.ProcessingReport{},
+				KeptFileName:     kept,
+			}
+			if l, ok := w.(Locator); ok {
+				resp.StoredPath = l.Locate(fn)
+			}
+			if v, ok := w.(Versioner); ok {
+				resp.Version = v.StoredVersion()
+			}
+			// by default, only formats with a Processor (JSON, YAML...) are post-processed, with the default rules,
+			// unless the client asks for something else
+			// (it's been checked there's a processor, if there's a spec)
+			switch process, _ := processorFor(contentType, detected, spec); {
+			case process == nil:
+			case u.jobs != nil:
+				// the upload is safely stored, no need to keep the client waiting on the rest
+				if resp.JobId, err = u.jobs.enqueue(fn, contentType, detected, rawSpec, gotDigest); err != nil {
+					log.Printf("failed to queue modifications to '%s': %s", fn, err)
+					return withDetails(status.Errorf(codes.Internal, "failed to queue modifications to uploaded data"),
+						resourceInfo("file", fn, ""), errorInfo(ReasonProcessingFailed, nil))
+				}
+				resp.Processing.ModifiedFileName = modifiedName(fn)
+			default:
 				// load data if a json file per bonus requirements, save a modified copy
-				if err := ProcessJSON(fn, u.io_thingee); err != nil {
-					return status.Errorf(codes.Internal, "failed to perform modifications to uploaded JSON data: %s", err)
+				report, err := runProcessor(process, fn, w, spec)
+				if err != nil {
+					return processingError(fn, err)
+				}
+				for _, le := range report.LineErrors {
+					resp.LineErrors = append(resp.LineErrors, &uploadpb.LineError{Line: uint64(le.Line), Error: le.Err.Error()})
+				}
+				resp.FailedLines = uint64(report.FailedLines)
+				resp.Processing = &uploadpb.ProcessingReport{
+					Processed:        true,
+					ModifiedFileName: modifiedName(fn),
+					KeysRemoved:      uint64(report.KeysRemoved),
+					IntsRewritten:    uint64(report.IntsRewritten),
+					IntsSaturated:    uint64(report.IntsSaturated),
+					ProcessingMicros: uint64(report.Elapsed.Microseconds()),
 				}
 			}
-			return stream.SendAndClose(&uploadpb.UploadResponse{
-				FileName: fn,
-				Size:     size,
-			})
+			return stream.SendAndClose(resp)
 		}
 		if err != nil {
 			return status.Errorf(codes.Internal, "failed to receive chunk: %s", err)
 		}
 
-		if _, err := u.io_thingee.Write(req.GetChunk()); err != nil {
-			return status.Errorf(codes.Internal, "failed to write chunk to file: %s", err)
+		chunk := req.GetChunk()
+		if req.Crc32C != nil && crc32.Checksum(chunk, castagnoli) != req.GetCrc32C() {
+			return withDetails(status.Errorf(codes.DataLoss, "crc32c mismatch for chunk at offset %d", size),
+				errorInfo(ReasonChecksumMismatch, map[string]string{"checksum": "crc32c", "offset": strconv.FormatUint(size, 10)}))
+		}
+		if sum := req.GetSha256(); len(sum) > 0 {
+			if len(sum) != sha256.Size {
+				return withDetails(status.Errorf(codes.InvalidArgument, "sha256 must be %d bytes, got %d", sha256.Size, len(sum)),
+					fieldViolation("sha256", fmt.Sprintf("must be %d bytes", sha256.Size)))
+			}
+			wantDigest = sum
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return storageError("write chunk to file", fn, err)
+		}
+		digest.Write(chunk)
+		if !sniffed {
+			if sniff.Write(chunk); sniff.full() {
+				if err := detect(false); err != nil {
+					return err
+				}
+			}
 		}
-		size += uint32(len(req.GetChunk()))
+		size += uint64(len(chunk))
 		// get the next stream segment
 		req, err = stream.Recv()
 	}
 }
+
+// table for checking the optional per-chunk CRC-32C checksums
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// size of the chunks streamed back to clients by DownloadFile
+const downloadChunkSize = 64 * 1024
+
+func (u *Uploader) DownloadFile(req *uploadpb.DownloadRequest, stream uploadpb.Uploader_DownloadFileServer) error {
+	fn := strings.TrimSpace(req.GetFileName())
+	// reject if no `file_name` argument provided
+	if fn == "" {
+		return withDetails(status.Errorf(codes.InvalidArgument, "missing file_name arg"), fieldViolation("file_name", "a file name is required"))
+	}
+	if err := checkFileName(fn); err != nil {
+		return fileNameError(fn, err)
+	}
+	if req.GetModified() {
+		fn = modifiedName(fn)
+	}
+	w, err := u.versionWriter(req.GetVersion())
+	if err != nil {
+		return err
+	}
+	r, err := w.Load(fn)
+	if errors.Is(err, ErrUnsafeFileName) {
+		return fileNameError(fn, err)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return withDetails(status.Errorf(codes.NotFound, "no such file '%s'", fn), resourceInfo("file", fn, "not found"))
+	}
+	if err != nil {
+		return storageError("open file", fn, err)
+	}
+	defer r.Close()
+
+	// read the file from storage one chunk at a time, only ever holding a single chunk in memory
+	for {
+		chunk := make([]byte, downloadChunkSize)
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			if err := stream.Send(&uploadpb.DownloadResponse{Chunk: chunk[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return storageError("read chunk from file", fn, err)
+		}
+	}
+}
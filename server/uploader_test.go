@@ -1,20 +1,29 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"io/fs"
 	"log"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	uploadpb "github.com/benjamin-rood/x-grpc/proto"
 	"github.com/go-test/deep"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestUploaderService_UploadFile(t *testing.T) {
@@ -48,7 +57,7 @@ func TestUploaderService_UploadFile(t *testing.T) {
 	}
 	// confirm modified data is as expected
 	// get resulting modified json
-	modifiedData, _ := buf.loadCurrent()
+	modifiedData, _ := loadAll(buf, "modified_"+fn)
 	// Compare the modified JSON with the expected output
 	// Unmarshal resulting json blob into a map to make comparison easier
 	modifiedDataMap := map[string]any{}
@@ -66,6 +75,366 @@ func TestUploaderService_UploadFile(t *testing.T) {
 	// TODO: make set of test cases
 }
 
+func TestUploaderService_UploadFile_ProcessingReport(t *testing.T) {
+	cases := []struct {
+		testName      string
+		fileName      string
+		mimeType      string
+		transformSpec string
+		data          string
+		want          *uploadpb.ProcessingReport // without the timing
+	}{
+		{"json", "fruit.json", "application/json", "", `{"apple": 1, "fig": 2, "kiwi": [4, {"egg": 6, "pear": 7}]}`,
+			&uploadpb.ProcessingReport{Processed: true, ModifiedFileName: "modified_fruit.json", KeysRemoved: 2, IntsRewritten: 2}},
+		{"csv columns count once", "fruit.csv", "text/csv", "", "apple,fig\n1,2\n3,4\n",
+			&uploadpb.ProcessingReport{Processed: true, ModifiedFileName: "modified_fruit.csv", KeysRemoved: 1, IntsRewritten: 2}},
+		{"saturated ints count once each", "big.json", "application/json",
+			`{"rules": [{"rule": "scale-even-ints", "limit": "int32", "on_overflow": "saturate"}]}`, `[2, 4000000, -4000000, 6000000]`,
+			&uploadpb.ProcessingReport{Processed: true, ModifiedFileName: "modified_big.json", IntsRewritten: 4, IntsSaturated: 3}},
+		{"unchanged", "fig.json", "application/json", "", `{"fig": 3}`,
+			&uploadpb.ProcessingReport{Processed: true, ModifiedFileName: "modified_fig.json"}},
+		{"nothing to process", "notes.txt", "text/plain", "", "hello",
+			&uploadpb.ProcessingReport{}},
+		{"processing turned off", "fruit.json", "application/json", `{"rules": []}`, `{"apple": 1}`,
+			&uploadpb.ProcessingReport{}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			uploadSvc := NewCustomUploader(NewBufferWriter())
+			conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+				uploadpb.RegisterUploaderServer(srv, uploadSvc)
+			})
+			client := uploadpb.NewUploaderClient(conn)
+
+			resp, err := sendRequestsToServer(t, client, []*uploadpb.UploadRequest{{
+				FileName:      tt.fileName,
+				MimeType:      tt.mimeType,
+				Chunk:         []byte(tt.data),
+				TransformSpec: tt.transformSpec,
+			}})
+			if err != nil {
+				t.Fatalf("client.UploadFile: %s", err)
+			}
+			if resp.GetStoredPath() != tt.fileName {
+				t.Errorf("expected stored path '%s', got '%s'", tt.fileName, resp.GetStoredPath())
+			}
+			got := resp.GetProcessing()
+			got.ProcessingMicros = 0
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("expected processing report %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUploaderService_UploadFile_Checksums(t *testing.T) {
+	buf := NewBufferWriter()
+	uploadSvc := NewCustomUploader(buf)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	digest := sha256.Sum256([]byte(haikuString))
+	wrongDigest := sha256.Sum256([]byte(smileyFace))
+	lines := strings.SplitAfter(haikuString, "\n")
+	// build the stream of messages for the haiku, one line per chunk
+	messages := func(crc func(chunk []byte) *uint32, sum []byte) []*uploadpb.UploadRequest {
+		reqs := []*uploadpb.UploadRequest{}
+		for _, line := range lines {
+			reqs = append(reqs, &uploadpb.UploadRequest{
+				FileName: "haiku.txt",
+				Chunk:    []byte(line),
+				Crc32C:   crc([]byte(line)),
+			})
+		}
+		// digest goes with the last message, once the whole file has been "read"
+		reqs[len(reqs)-1].Sha256 = sum
+		return reqs
+	}
+	noCRC := func([]byte) *uint32 { return nil }
+	goodCRC := func(chunk []byte) *uint32 {
+		sum := crc32.Checksum(chunk, castagnoli)
+		return &sum
+	}
+	badCRC := func(chunk []byte) *uint32 {
+		sum := crc32.Checksum(chunk, castagnoli) + 1
+		return &sum
+	}
+
+	cases := []struct {
+		testName string
+		reqs     []*uploadpb.UploadRequest
+		code     codes.Code
+	}{
+		{"no checksums", messages(noCRC, nil), codes.OK},
+		{"matching chunk checksums", messages(goodCRC, nil), codes.OK},
+		{"matching file digest", messages(noCRC, digest[:]), codes.OK},
+		{"matching chunk checksums and file digest", messages(goodCRC, digest[:]), codes.OK},
+		{"chunk checksum mismatch", messages(badCRC, digest[:]), codes.DataLoss},
+		{"file digest mismatch", messages(goodCRC, wrongDigest[:]), codes.DataLoss},
+		{"truncated file digest", messages(noCRC, digest[:16]), codes.InvalidArgument},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			resp, err := sendRequestsToServer(t, client, tt.reqs)
+			if status.Code(err) != tt.code {
+				t.Fatalf("expected %s, got: %v", tt.code, err)
+			}
+			if err != nil {
+				return
+			}
+			// server always reports the digest of what it stored
+			if !bytes.Equal(resp.GetSha256(), digest[:]) {
+				t.Errorf("expected sha256 %x, got %x", digest, resp.GetSha256())
+			}
+		})
+	}
+}
+
+// sends pre-built upload messages to the server as they are
+func sendRequestsToServer(t *testing.T, client uploadpb.UploaderClient, reqs []*uploadpb.UploadRequest) (*uploadpb.UploadResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	t.Cleanup(func() {
+		cancel()
+	})
+	stream, err := client.UploadFile(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %s", err)
+	}
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			break // server has given up on the stream, find out why below
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// run with `go test -race` to check that concurrent streams don't share any writer state
+func TestUploaderService_UploadFile_Concurrent(t *testing.T) {
+	buf := NewBufferWriter()
+	uploadSvc := NewCustomUploader(buf)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	const uploads = 48
+	sent := make([]string, uploads)
+	for i := range sent {
+		// vary the content & length of each upload so any interleaving would show up
+		sent[i] = strings.Repeat(fmt.Sprintf("upload #%d\n", i), i+1)
+		if i%4 == 0 {
+			sent[i] = jsonBlob
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, uploads)
+	for i, data := range sent {
+		wg.Add(1)
+		go func(fn, data string) {
+			defer wg.Done()
+			mimeType := "text/plain"
+			if data == jsonBlob {
+				mimeType = "application/json"
+			}
+			if _, err := sendDataInChunksToServer(t, client, data, fn, mimeType); err != nil {
+				errs <- fmt.Errorf("%s: %w", fn, err)
+			}
+		}(fmt.Sprintf("file%02d", i), data)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("client.UploadFile: %s", err)
+	}
+
+	for i, want := range sent {
+		fn := fmt.Sprintf("file%02d", i)
+		got, found := buf.m[fn]
+		if !found {
+			t.Errorf("cannot find `%s` entry", fn)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s: STORED DATA ≠ SENT DATA\n%s\n≠\n%s", fn, got, want)
+		}
+		if want == jsonBlob {
+			if _, found := buf.m["modified_"+fn]; !found {
+				t.Errorf("cannot find `modified_%s` entry", fn)
+			}
+		}
+	}
+}
+
+// keeps count of what's written, and nothing else
+type discardWriter struct {
+	written *atomic.Uint64 // shared between sessions
+}
+
+func (d *discardWriter) NewWriter() OpenWriteCloserLoader { return &discardWriter{written: d.written} }
+func (d *discardWriter) Open(string) error                { return nil }
+func (d *discardWriter) Close() error                     { return nil }
+func (d *discardWriter) Abort() error                     { return nil }
+
+func (d *discardWriter) Write(p []byte) (int, error) {
+	d.written.Add(uint64(len(p)))
+	return len(p), nil
+}
+
+func (d *discardWriter) Load(filename string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("nothing kept of '%s': %w", filename, fs.ErrNotExist)
+}
+
+// an UploadFile stream of `remaining` zero bytes, generated as the server receives them
+type syntheticUploadStream struct {
+	grpc.ServerStream
+	chunk     []byte
+	remaining uint64
+	resp      *uploadpb.UploadResponse
+}
+
+func (s *syntheticUploadStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *syntheticUploadStream) Recv() (*uploadpb.UploadRequest, error) {
+	if s.remaining == 0 {
+		return nil, io.EOF
+	}
+	chunk := s.chunk
+	if uint64(len(chunk)) > s.remaining {
+		chunk = chunk[:s.remaining]
+	}
+	s.remaining -= uint64(len(chunk))
+	return &uploadpb.UploadRequest{FileName: "huge", Chunk: chunk}, nil
+}
+
+func (s *syntheticUploadStream) SendAndClose(resp *uploadpb.UploadResponse) error {
+	s.resp = resp
+	return nil
+}
+
+func TestUploaderService_UploadFile_Over4GiB(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 4 GiB upload in short mode")
+	}
+	// past where a 32-bit size would wrap around, with a partial chunk at the end
+	const size = 1<<32 + 12345
+	written := &atomic.Uint64{}
+	uploadSvc := NewCustomUploader(&discardWriter{written: written})
+	// straight to the service, skipping the transport, which would only slow things down
+	stream := &syntheticUploadStream{chunk: make([]byte, 4<<20), remaining: size}
+	if err := uploadSvc.UploadFile(stream); err != nil {
+		t.Fatalf("UploadFile: %s", err)
+	}
+	if got := stream.resp.GetSize(); got != size {
+		t.Errorf("expected size %d, got %d", uint64(size), got)
+	}
+	if got := written.Load(); got != size {
+		t.Errorf("expected %d bytes written, got %d", uint64(size), got)
+	}
+}
+
+func TestUploaderService_DownloadFile(t *testing.T) {
+	// big enough to need several chunks
+	bigData := strings.Repeat(smileyFace, 3*downloadChunkSize/len(smileyFace)+1)
+	cases := []struct {
+		testName string
+		writers  WriterFactory
+	}{
+		{"in-memory storage", NewBufferWriter()},
+		{"on-disk storage", newTempDiskWriter(t)},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			uploadSvc := NewCustomUploader(tt.writers)
+			conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+				uploadpb.RegisterUploaderServer(srv, uploadSvc)
+			})
+			client := uploadpb.NewUploaderClient(conn)
+
+			if _, err := sendDataInChunksToServer(t, client, jsonBlob, "testBlob", "application/json"); err != nil {
+				t.Fatalf("client.UploadFile: %s", err)
+			}
+			if _, err := sendDataInChunksToServer(t, client, bigData, "bigFile", "text/plain"); err != nil {
+				t.Fatalf("client.UploadFile: %s", err)
+			}
+
+			// original upload comes back exactly as sent
+			got, err := receiveDataFromServer(t, client, "testBlob", false)
+			if err != nil {
+				t.Fatalf("client.DownloadFile: %s", err)
+			}
+			if string(got) != jsonBlob {
+				t.Errorf("DOWNLOADED DATA ≠ SENT DATA\n%s\n≠\n%s", got, jsonBlob)
+			}
+			// modified copy matches what was written by ProcessJSON
+			got, err = receiveDataFromServer(t, client, "testBlob", true)
+			if err != nil {
+				t.Fatalf("client.DownloadFile: %s", err)
+			}
+			modifiedData, _ := loadAll(tt.writers.NewWriter(), "modified_testBlob")
+			if string(got) != string(modifiedData) {
+				t.Errorf("DOWNLOADED DATA ≠ MODIFIED DATA\n%s\n≠\n%s", got, modifiedData)
+			}
+			// large files are streamed back across multiple chunks
+			got, err = receiveDataFromServer(t, client, "bigFile", false)
+			if err != nil {
+				t.Fatalf("client.DownloadFile: %s", err)
+			}
+			if string(got) != bigData {
+				t.Errorf("DOWNLOADED DATA ≠ SENT DATA (%d bytes ≠ %d bytes)", len(got), len(bigData))
+			}
+
+			// missing files, or a missing file name
+			if _, err := receiveDataFromServer(t, client, "noSuchFile", false); status.Code(err) != codes.NotFound {
+				t.Errorf("expected NotFound, got: %v", err)
+			}
+			if _, err := receiveDataFromServer(t, client, "bigFile", true); status.Code(err) != codes.NotFound {
+				t.Errorf("expected NotFound, got: %v", err)
+			}
+			if _, err := receiveDataFromServer(t, client, "", false); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("expected InvalidArgument, got: %v", err)
+			}
+		})
+	}
+}
+
+// downloads a file from the server, checking that no chunk exceeds `downloadChunkSize`
+func receiveDataFromServer(t *testing.T, client uploadpb.UploaderClient, fileName string, modified bool) ([]byte, error) {
+	return downloadFromServer(t, client, &uploadpb.DownloadRequest{
+		FileName: fileName,
+		Modified: modified,
+	})
+}
+
+func downloadFromServer(t *testing.T, client uploadpb.UploaderClient, req *uploadpb.DownloadRequest) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	t.Cleanup(func() {
+		cancel()
+	})
+	stream, err := client.DownloadFile(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %s", err)
+	}
+	var data []byte
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.GetChunk()) > downloadChunkSize {
+			t.Errorf("received chunk of %d bytes, exceeds %d", len(resp.GetChunk()), downloadChunkSize)
+		}
+		data = append(data, resp.GetChunk()...)
+	}
+}
+
 func sendDataInChunksToServer(t *testing.T, client uploadpb.UploaderClient, data string, fileName string, mimeType string) (*uploadpb.UploadResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	t.Cleanup(func() {
@@ -107,6 +476,47 @@ func sendDataInChunksToServer(t *testing.T, client uploadpb.UploaderClient, data
 	return stream.CloseAndRecv()
 }
 
+// a diskWriter writing to a directory which is cleaned up after the test
+func newTempDiskWriter(t *testing.T) *diskWriter {
+	dw, err := newDiskWriter(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		dw.root.Close()
+	})
+	return dw
+}
+
+// a client for an Uploader on `writers` (opened with err, which fails the test), with the
+// uploader stopped and any directory the writers have open closed once the test is done
+func newTestClient(t *testing.T, writers WriterFactory, err error, opts ...UploaderOption) (uploadpb.UploaderClient, *Uploader) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dw *diskWriter
+	switch w := writers.(type) {
+	case *diskWriter:
+		dw = w
+	case *versionedWriter:
+		dw = w.diskWriter
+	case *casWriter:
+		dw = w.diskWriter
+	}
+	if dw != nil {
+		t.Cleanup(func() {
+			dw.root.Close()
+		})
+	}
+	uploadSvc := NewCustomUploader(writers, opts...)
+	t.Cleanup(uploadSvc.Stop)
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	return uploadpb.NewUploaderClient(conn), uploadSvc
+}
+
 // handy helper stolen from github.com/MarioCarrion/grpc-microservice-example
 func newTestGRPCServer(t *testing.T, register func(srv *grpc.Server)) *grpc.ClientConn {
 	lis := bufconn.Listen(1024 * 1024)
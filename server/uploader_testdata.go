@@ -121,5 +121,151 @@ Nature's hidden gem.`
 0000000000000000000000000000000000000000
 0000000000000000000000000000000000000000
 0000000000000000000000000000000000000000
+`
+
+	// jsonBlob, as YAML
+	yamlBlob = `---
+# same data as jsonBlob
+nestedData:
+  apple: 3
+  banana: 8
+  carrot: 6
+  duck: 5
+  elephant: 12
+  fig: 2
+  integerArray: [1, 2, 3, 4, 5]
+  anotherObject:
+    grape: 9
+    honeydew: 7
+    papaya: 20
+  yetAnotherObject:
+    orange: 4
+    pear: 11
+    watermelon: 7
+    kiwi: 10
+name: John Doe
+age: 30
+isStudent: true
+hobbies:
+  - reading
+  - cooking
+  - photography
+address:
+  street: 123 Main Street
+  city: New York
+  country: USA
+  geolocation:
+    latitude: 40.7128
+    longitude: -74.0060
+favoriteColor: null
+floatNumber: 3.14
+scientificNotation: 1.23e-4
+emptyString: ""
+escapedString: This is a "quoted" string.
+unicodeString: "\u0048\u0065\u006C\u006C\u006F"
+emptyArray: []
+emptyObject: {}
+date: "2023-05-24"
+timestamp: 1672467000
+positiveInfinity: Infinity
+negativeInfinity: -Infinity
+notANumber: NaN
+`
+
+	expectedYAMLOutput = `# same data as jsonBlob
+nestedData:
+  banana: 8000
+  carrot: 6000
+  duck: 5
+  fig: 2000
+  yetAnotherObject:
+    pear: 11
+    watermelon: 7
+    kiwi: 10000
+name: John Doe
+hobbies:
+  - reading
+  - cooking
+  - photography
+favoriteColor: null
+floatNumber: 3.14
+scientificNotation: 1.23e-4
+date: "2023-05-24"
+timestamp: 1672467000000
+positiveInfinity: Infinity
+negativeInfinity: -Infinity
+notANumber: NaN
+`
+
+	// jsonBlob, as TOML (which has no null, so no favoriteColor)
+	tomlBlob = `# same data as jsonBlob
+name = "John Doe"
+age = 30
+isStudent = true
+hobbies = ["reading", "cooking", "photography"]
+floatNumber = 3.14
+scientificNotation = 1.23e-4
+emptyString = ""
+escapedString = "This is a \"quoted\" string."
+unicodeString = "\u0048\u0065\u006C\u006C\u006F"
+emptyArray = []
+date = 2023-05-24
+timestamp = 1672467000
+positiveInfinity = inf
+negativeInfinity = -inf
+notANumber = nan
+
+[nestedData]
+apple = 3
+banana = 8
+carrot = 6
+duck = 5
+elephant = 12
+fig = 2
+integerArray = [1, 2, 3, 4, 5]
+
+[nestedData.anotherObject]
+grape = 9
+honeydew = 7
+papaya = 20
+
+[nestedData.yetAnotherObject]
+orange = 4
+pear = 11
+watermelon = 7
+kiwi = 10
+
+[address]
+street = "123 Main Street"
+city = "New York"
+country = "USA"
+
+[address.geolocation]
+latitude = 40.7128
+longitude = -74.0060
+
+[emptyObject]
+`
+
+	// keys sorted, comments gone, as written by the toml package
+	expectedTOMLOutput = `date = 2023-05-24
+floatNumber = 3.14
+hobbies = ["reading", "cooking", "photography"]
+name = "John Doe"
+negativeInfinity = -inf
+notANumber = nan
+positiveInfinity = +inf
+scientificNotation = 0.000123
+timestamp = 1672467000000
+
+[nestedData]
+  banana = 8000
+  carrot = 6000
+  duck = 5
+  fig = 2000
+  [nestedData.yetAnotherObject]
+    kiwi = 10000
+    pear = 11
+    watermelon = 7
 `
 )
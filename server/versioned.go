@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Version is one of the versions kept of a file
+type Version struct {
+	ID      string
+	Size    int64
+	Created time.Time
+}
+
+// Versioner is implemented by writers which keep earlier versions of a file when it's replaced
+type Versioner interface {
+	// Versions lists the versions kept of a file, oldest first (none for a file never stored)
+	Versions(filename string) ([]Version, error)
+	// LoadVersion is Load, for the given version of a file rather than the latest;
+	// an error wrapping fs.ErrNotExist if there's no such version
+	LoadVersion(filename, version string) (io.ReadCloser, error)
+	// StoredVersion returns the version the last file stored became
+	StoredVersion() string
+}
+
+/**
+ * versionedWriter is a diskWriter which never replaces a file, but keeps every version of it:
+ * each file is a directory, with a file per version numbered from 1, e.g. `kiwi.json/1`,
+ * `kiwi.json/2`... Loading a file gets the latest version. Everything else works the same as
+ * for a diskWriter (uploads are written to a temp file, partial uploads are kept in `.partial`...),
+ * only where the file ends up is different.
+ *
+ * With `retain` set, only that many of the latest versions of each file are kept, older ones are
+ * deleted as new ones are stored.
+ *
+ * A file stored by a plain diskWriter, before versions were kept, is version 1 of itself, until
+ * another version of it is stored, when it's moved to `kiwi.json/1` first.
+ */
+type versionedWriter struct {
+	*diskWriter
+	retain  int    // versions kept of each file, 0 for every one of them
+	version string // the last file stored became
+}
+
+// creates the directory if need be, for a versionedWriter writing to it
+func newVersionedWriter(dir string, retain int) (*versionedWriter, error) {
+	dw, err := newDiskWriter(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &versionedWriter{diskWriter: dw, retain: retain}, nil
+}
+
+// Check interface conformity
+var (
+	_ OpenWriteCloserLoader = &versionedWriter{}
+	_ WriterFactory         = &versionedWriter{}
+	_ Resumer               = &versionedWriter{}
+	_ Locator               = &versionedWriter{}
+	_ ConflictResolver      = &versionedWriter{}
+	_ Versioner             = &versionedWriter{}
+)
+
+// returns a new versionedWriter session writing to the same directory
+func (vw *versionedWriter) NewWriter() OpenWriteCloserLoader {
+	return &versionedWriter{diskWriter: vw.diskWriter.NewWriter().(*diskWriter), retain: vw.retain}
+}
+
+func (vw *versionedWriter) Close() error {
+	if err := vw.closeFile(); err != nil || vw.tmp == "" {
+		return err
+	}
+	if err := vw.store(vw.tmp, vw.name); err != nil {
+		return err
+	}
+	vw.tmp = ""
+	return nil
+}
+
+func (vw *versionedWriter) Commit(uploadID, filename string) error {
+	if err := vw.checkPath(filename); err != nil {
+		return err
+	}
+	return vw.store(vw.partialPath(uploadID), filename)
+}
+
+// renames a complete file into place as the next version of `filename`, or of wherever
+// the conflict policy says; keeping versions is what happens anyway
+func (vw *versionedWriter) store(from, filename string) error {
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	policy := vw.policy
+	vw.policy = Overwrite
+	if policy == KeepVersions {
+		policy = Overwrite
+	}
+	stored, _, err := resolveConflict(filename, policy, func(name string) (bool, error) {
+		versions, err := vw.versionNumbers(name)
+		return len(versions) > 0, err
+	})
+	if err != nil {
+		return err
+	}
+	if err := vw.migrate(stored); err != nil {
+		return err
+	}
+	versions, err := vw.versionNumbers(stored)
+	if err != nil {
+		return err
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1] + 1
+	}
+	if err := vw.root.MkdirAll(stored, os.ModePerm); err != nil {
+		return err
+	}
+	version := strconv.Itoa(next)
+	if err := vw.root.Rename(from, path.Join(stored, version)); err != nil {
+		return err
+	}
+	vw.stored, vw.kept, vw.version = stored, "", version
+	if err := vw.syncDir(stored); err != nil {
+		return err
+	}
+	if err := vw.syncDir("."); err != nil {
+		return err
+	}
+	// past retention
+	versions = append(versions, next)
+	for vw.retain > 0 && len(versions) > vw.retain {
+		old := path.Join(stored, strconv.Itoa(versions[0]))
+		log.Printf("removing old version '%s'\n", vw.diskWriter.Locate(old))
+		if err := vw.root.Remove(old); err != nil {
+			return err
+		}
+		versions = versions[1:]
+	}
+	return nil
+}
+
+// the versions of a file, in order; vw.mu must be held, so they don't change in the meantime
+func (vw *versionedWriter) versionNumbers(filename string) ([]int, error) {
+	info, err := vw.root.Lstat(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		// stored before versions were kept
+		return []int{1}, nil
+	}
+	d, err := vw.root.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	var versions []int
+	for _, name := range names {
+		if n, ok := parseVersion(name); ok {
+			versions = append(versions, n)
+		}
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// where a version of a file is, which for a file stored before versions were kept
+// is the file itself; vw.mu must be held
+func (vw *versionedWriter) versionPath(filename string, version int) (string, error) {
+	info, err := vw.root.Lstat(filename)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		if version != 1 {
+			return "", fmt.Errorf("no such version %d of '%s': %w", version, filename, fs.ErrNotExist)
+		}
+		return filename, nil
+	}
+	return path.Join(filename, strconv.Itoa(version)), nil
+}
+
+// moves a file stored before versions were kept to version 1, to make room for more
+// alongside it; vw.mu must be held
+func (vw *versionedWriter) migrate(filename string) error {
+	info, err := vw.root.Lstat(filename)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("moving '%s' to version 1\n", vw.diskWriter.Locate(filename))
+	// out of the way under a temp name, as a directory is about to take its place
+	f, tmp, err := vw.createTemp()
+	if err != nil {
+		return err
+	}
+	f.Close()
+	if err := vw.root.Rename(filename, tmp); err != nil {
+		vw.root.Remove(tmp)
+		return err
+	}
+	if err := vw.root.Mkdir(filename, os.ModePerm); err != nil {
+		// put it back
+		return errors.Join(err, vw.root.Rename(tmp, filename))
+	}
+	if err := vw.root.Rename(tmp, path.Join(filename, "1")); err != nil {
+		return err
+	}
+	if err := vw.syncDir(filename); err != nil {
+		return err
+	}
+	return vw.syncDir(".")
+}
+
+// versions are positive numbers, written the one way
+func parseVersion(version string) (int, bool) {
+	n, err := strconv.Atoi(version)
+	return n, err == nil && n > 0 && strconv.Itoa(n) == version
+}
+
+// loads the latest version
+func (vw *versionedWriter) Load(filename string) (io.ReadCloser, error) {
+	if err := vw.checkPath(filename); err != nil {
+		return nil, err
+	}
+	// open it before anything can prune it
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	versions, err := vw.versionNumbers(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no such file '%s': %w", filename, fs.ErrNotExist)
+	}
+	p, err := vw.versionPath(filename, versions[len(versions)-1])
+	if err != nil {
+		return nil, err
+	}
+	return vw.root.Open(p)
+}
+
+func (vw *versionedWriter) LoadVersion(filename, version string) (io.ReadCloser, error) {
+	if err := vw.checkPath(filename); err != nil {
+		return nil, err
+	}
+	n, ok := parseVersion(version)
+	if !ok {
+		return nil, fmt.Errorf("no such version '%s' of '%s': %w", version, filename, fs.ErrNotExist)
+	}
+	// open it before anything can prune it
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	p, err := vw.versionPath(filename, n)
+	if err != nil {
+		return nil, err
+	}
+	return vw.root.Open(p)
+}
+
+func (vw *versionedWriter) Versions(filename string) ([]Version, error) {
+	if err := vw.checkPath(filename); err != nil {
+		return nil, err
+	}
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	numbers, err := vw.versionNumbers(filename)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]Version, 0, len(numbers))
+	for _, n := range numbers {
+		p, err := vw.versionPath(filename, n)
+		if err != nil {
+			return nil, err
+		}
+		info, err := vw.root.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, Version{ID: strconv.Itoa(n), Size: info.Size(), Created: info.ModTime()})
+	}
+	return versions, nil
+}
+
+func (vw *versionedWriter) StoredVersion() string {
+	return vw.version
+}
+
+// where the version just stored is, or failing that, the latest
+func (vw *versionedWriter) Locate(filename string) string {
+	if filename == vw.stored && vw.version != "" {
+		return filepath.Join(vw.root.Name(), filename, vw.version)
+	}
+	vw.mu.Lock()
+	defer vw.mu.Unlock()
+	if versions, err := vw.versionNumbers(filename); err == nil && len(versions) > 0 {
+		if p, err := vw.versionPath(filename, versions[len(versions)-1]); err == nil {
+			return vw.diskWriter.Locate(p)
+		}
+	}
+	return vw.diskWriter.Locate(filename)
+}
+
+// versionLoader is a writer session which loads the given version of a file, rather than the latest
+type versionLoader struct {
+	OpenWriteCloserLoader
+	version string
+}
+
+func (l versionLoader) Load(filename string) (io.ReadCloser, error) {
+	return l.OpenWriteCloserLoader.(Versioner).LoadVersion(filename, l.version)
+}
+
+// a writer session for loading the given version of files (the latest, if it's ""),
+// failing with `UNIMPLEMENTED` if the writer doesn't keep them
+func (u *Uploader) versionWriter(version string) (OpenWriteCloserLoader, error) {
+	w := u.io_thingee.NewWriter()
+	if version == "" {
+		return w, nil
+	}
+	if _, ok := w.(Versioner); !ok {
+		return nil, withDetails(status.Errorf(codes.Unimplemented, "versions of files are not kept"), fieldViolation("version", "versions of files are not kept"))
+	}
+	return versionLoader{OpenWriteCloserLoader: w, version: version}, nil
+}
+
+func (u *Uploader) ListVersions(ctx context.Context, req *uploadpb.ListVersionsRequest) (*uploadpb.ListVersionsResponse, error) {
+	fn := strings.TrimSpace(req.GetFileName())
+	if fn == "" {
+		return nil, withDetails(status.Errorf(codes.InvalidArgument, "missing file_name arg"), fieldViolation("file_name", "a file name is required"))
+	}
+	if err := checkFileName(fn); err != nil {
+		return nil, fileNameError(fn, err)
+	}
+	if req.GetModified() {
+		fn = modifiedName(fn)
+	}
+	versioner, ok := u.io_thingee.NewWriter().(Versioner)
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "versions of files are not kept")
+	}
+	versions, err := versioner.Versions(fn)
+	if errors.Is(err, ErrUnsafeFileName) {
+		return nil, fileNameError(fn, err)
+	}
+	if err != nil {
+		return nil, storageError("list versions", fn, err)
+	}
+	if len(versions) == 0 {
+		return nil, withDetails(status.Errorf(codes.NotFound, "no such file '%s'", fn), resourceInfo("file", fn, "not found"))
+	}
+	resp := &uploadpb.ListVersionsResponse{FileName: fn}
+	for _, v := range versions {
+		resp.Versions = append(resp.Versions, &uploadpb.FileVersion{
+			Version:           v.ID,
+			Size:              uint64(v.Size),
+			CreatedUnixMicros: uint64(v.Created.UnixMicro()),
+		})
+	}
+	return resp, nil
+}
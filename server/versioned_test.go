@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// the IDs and sizes of a file's versions
+func listVersions(t *testing.T, client uploadpb.UploaderClient, fileName string, modified bool) ([]string, []uint64) {
+	t.Helper()
+	resp, err := client.ListVersions(context.Background(), &uploadpb.ListVersionsRequest{FileName: fileName, Modified: modified})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	var sizes []uint64
+	for _, v := range resp.GetVersions() {
+		ids = append(ids, v.GetVersion())
+		sizes = append(sizes, v.GetSize())
+		if v.GetCreatedUnixMicros() == 0 {
+			t.Errorf("expected version %s to have a creation time", v.GetVersion())
+		}
+	}
+	return ids, sizes
+}
+
+func TestUploaderService_Versions(t *testing.T) {
+	vw, err := newVersionedWriter(t.TempDir(), 0)
+	client, _ := newTestClient(t, vw, err)
+
+	for i, data := range []string{"one", "two", "three!"} {
+		resp, err := sendDataInChunksToServer(t, client, data, "kiwi.txt", "text/plain")
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantVersion := []string{"1", "2", "3"}[i]
+		if resp.Version != wantVersion || resp.StoredPath != filepath.Join(vw.root.Name(), "kiwi.txt", wantVersion) {
+			t.Errorf("expected version %s, got %s stored at %s", wantVersion, resp.Version, resp.StoredPath)
+		}
+	}
+	ids, sizes := listVersions(t, client, "kiwi.txt", false)
+	if !slices.Equal(ids, []string{"1", "2", "3"}) || !slices.Equal(sizes, []uint64{3, 3, 6}) {
+		t.Errorf("expected versions 1, 2 & 3 of 3, 3 & 6 bytes, got %v of %v", ids, sizes)
+	}
+	for version, want := range map[string]string{"": "three!", "1": "one", "2": "two", "3": "three!"} {
+		got, err := downloadFromServer(t, client, &uploadpb.DownloadRequest{FileName: "kiwi.txt", Version: version})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("expected version %q to be %q, got %q", version, want, got)
+		}
+	}
+	for _, version := range []string{"4", "0", "01", "-1", "abc", "../kiwi.txt"} {
+		_, err := downloadFromServer(t, client, &uploadpb.DownloadRequest{FileName: "kiwi.txt", Version: version})
+		checkDetails(t, err, wantDetails{code: codes.NotFound, resource: "kiwi.txt"})
+	}
+	_, err = client.ListVersions(context.Background(), &uploadpb.ListVersionsRequest{FileName: "feijoa.txt"})
+	checkDetails(t, err, wantDetails{code: codes.NotFound, resource: "feijoa.txt"})
+	_, err = client.ListVersions(context.Background(), &uploadpb.ListVersionsRequest{FileName: "../kiwi.txt"})
+	checkDetails(t, err, wantDetails{code: codes.InvalidArgument, field: "file_name"})
+}
+
+func TestUploaderService_Versions_Retention(t *testing.T) {
+	vw, err := newVersionedWriter(t.TempDir(), 2)
+	client, _ := newTestClient(t, vw, err)
+
+	for _, data := range []string{"one", "two", "three", "four"} {
+		if _, err := sendDataInChunksToServer(t, client, data, "kiwi.txt", "text/plain"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids, _ := listVersions(t, client, "kiwi.txt", false); !slices.Equal(ids, []string{"3", "4"}) {
+		t.Errorf("expected only versions 3 & 4 to be kept, got %v", ids)
+	}
+	if got := dirNames(t, filepath.Join(vw.root.Name(), "kiwi.txt")); !slices.Equal(got, []string{"3", "4"}) {
+		t.Errorf("expected only versions 3 & 4 on disk, got %v", got)
+	}
+	_, err = downloadFromServer(t, client, &uploadpb.DownloadRequest{FileName: "kiwi.txt", Version: "1"})
+	checkDetails(t, err, wantDetails{code: codes.NotFound, resource: "kiwi.txt"})
+}
+
+func TestUploaderService_Versions_ResumableAndConflicts(t *testing.T) {
+	vw, err := newVersionedWriter(t.TempDir(), 0)
+	client, _ := newTestClient(t, vw, err)
+
+	if _, err := sendResumableDataInChunksToServer(t, client, `{"kiwi": 2}`, "kiwi.json", "application/json", "first", 0); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := sendResumableDataInChunksToServer(t, client, `{"kiwi": 4}`, "kiwi.json", "application/json", "second", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Version != "2" {
+		t.Errorf("expected the second upload to be version 2, got %q", resp.Version)
+	}
+	// modified copies have versions of their own
+	if ids, _ := listVersions(t, client, "kiwi.json", true); !slices.Equal(ids, []string{"1", "2"}) {
+		t.Errorf("expected versions 1 & 2 of the modified copy, got %v", ids)
+	}
+	got, err := downloadFromServer(t, client, &uploadpb.DownloadRequest{FileName: "kiwi.json", Modified: true, Version: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"kiwi":2000}` {
+		t.Errorf("expected the first modified copy, got %s", got)
+	}
+
+	_, err = sendRequestsToServer(t, client, []*uploadpb.UploadRequest{
+		{FileName: "kiwi.json", Chunk: []byte("{}"), ConflictPolicy: uploadpb.ConflictPolicy_CONFLICT_POLICY_FAIL},
+	})
+	checkDetails(t, err, wantDetails{code: codes.AlreadyExists, resource: "kiwi.json", reason: ReasonFileExists})
+	resp, err = sendRequestsToServer(t, client, []*uploadpb.UploadRequest{
+		{FileName: "kiwi.json", Chunk: []byte("{}"), ConflictPolicy: uploadpb.ConflictPolicy_CONFLICT_POLICY_RENAME},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.FileName != "kiwi (1).json" || resp.Version != "1" {
+		t.Errorf("expected version 1 of 'kiwi (1).json', got version %s of '%s'", resp.Version, resp.FileName)
+	}
+	resp, err = sendRequestsToServer(t, client, []*uploadpb.UploadRequest{
+		{FileName: "kiwi.json", Chunk: []byte("{}"), ConflictPolicy: uploadpb.ConflictPolicy_CONFLICT_POLICY_KEEP_VERSIONS},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.FileName != "kiwi.json" || resp.Version != "3" || resp.KeptFileName != "" {
+		t.Errorf("expected version 3 of 'kiwi.json', got version %s of '%s' (keeping '%s')", resp.Version, resp.FileName, resp.KeptFileName)
+	}
+}
+
+// a directory written to before versions were kept, by a plain diskWriter
+func TestUploaderService_Versions_FromFlatFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"kiwi.json": `{"kiwi": 2}`, "modified_kiwi.json": `{"kiwi":2000}`, "feijoa.txt": "feijoa"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vw, err := newVersionedWriter(dir, 0)
+	client, _ := newTestClient(t, vw, err)
+	checkContents := func(req *uploadpb.DownloadRequest, want string) {
+		t.Helper()
+		got, err := downloadFromServer(t, client, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("expected version %q of %s to be %q, got %q", req.Version, req.FileName, want, got)
+		}
+	}
+
+	// each is version 1 of itself, until there's another
+	for _, name := range []string{"kiwi.json", "feijoa.txt"} {
+		if ids, sizes := listVersions(t, client, name, false); !slices.Equal(ids, []string{"1"}) || sizes[0] == 0 {
+			t.Errorf("expected %s to have version 1, got %v of %v", name, ids, sizes)
+		}
+	}
+	checkContents(&uploadpb.DownloadRequest{FileName: "feijoa.txt"}, "feijoa")
+	checkContents(&uploadpb.DownloadRequest{FileName: "feijoa.txt", Version: "1"}, "feijoa")
+	_, err = downloadFromServer(t, client, &uploadpb.DownloadRequest{FileName: "feijoa.txt", Version: "2"})
+	checkDetails(t, err, wantDetails{code: codes.NotFound, resource: "feijoa.txt"})
+
+	resp, err := sendDataInChunksToServer(t, client, `{"kiwi": 4}`, "kiwi.json", "application/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Version != "2" || resp.StoredPath != filepath.Join(dir, "kiwi.json", "2") {
+		t.Errorf("expected version 2, got %s stored at %s", resp.Version, resp.StoredPath)
+	}
+	for _, modified := range []bool{false, true} {
+		if ids, _ := listVersions(t, client, "kiwi.json", modified); !slices.Equal(ids, []string{"1", "2"}) {
+			t.Errorf("expected versions 1 & 2 (modified %t), got %v", modified, ids)
+		}
+	}
+	checkContents(&uploadpb.DownloadRequest{FileName: "kiwi.json", Version: "1"}, `{"kiwi": 2}`)
+	checkContents(&uploadpb.DownloadRequest{FileName: "kiwi.json"}, `{"kiwi": 4}`)
+	checkContents(&uploadpb.DownloadRequest{FileName: "kiwi.json", Modified: true, Version: "1"}, `{"kiwi":2000}`)
+	checkContents(&uploadpb.DownloadRequest{FileName: "kiwi.json", Modified: true}, `{"kiwi":4000}`)
+	if got := dirNames(t, filepath.Join(vw.root.Name(), "kiwi.json")); !slices.Equal(got, []string{"1", "2"}) {
+		t.Errorf("expected versions 1 & 2 on disk, got %v", got)
+	}
+}
+
+func TestUploaderService_Versions_Unsupported(t *testing.T) {
+	uploadSvc := NewCustomUploader(NewBufferWriter())
+	conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+		uploadpb.RegisterUploaderServer(srv, uploadSvc)
+	})
+	client := uploadpb.NewUploaderClient(conn)
+
+	_, err := client.ListVersions(context.Background(), &uploadpb.ListVersionsRequest{FileName: "kiwi.txt"})
+	checkDetails(t, err, wantDetails{code: codes.Unimplemented})
+	_, err = downloadFromServer(t, client, &uploadpb.DownloadRequest{FileName: "kiwi.txt", Version: "1"})
+	checkDetails(t, err, wantDetails{code: codes.Unimplemented, field: "version"})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+/**
+ * The properties that start with a vowel should be removed from the JSON data.
+ *
+ * Keys are looked at by their first character (rune, plus any combining marks), after
+ * Unicode normalisation, so "Élan" is treated the same whether its É is one code point or
+ * an E followed by a combining accent. Configuration (all optional):
+ *
+ *	{"preset": "en",     // set of vowels: "en" (the default), "es", "fr", "de", "nordic" or "tr"
+ *	 "accented": true,   // also count accented forms of the vowels, e.g. "Élan" or "über" for "en"
+ *	 "y": true}          // also count y as a vowel
+ */
+type dropVowelKeys struct {
+	noValues
+	vowels   string // lower case, NFC normalised; defaults to the "en" preset when empty
+	accented bool
+}
+
+// vowels for each preset, lower case only
+var vowelPresets = map[string]string{
+	"en":     "aeiou",
+	"es":     "aeiouáéíóúü",
+	"fr":     "aeiouyàâæéèêëîïôœùûüÿ",
+	"de":     "aeiouäöü",
+	"nordic": "aeiouyåæøäö",
+	"tr":     "aeıioöuü",
+}
+
+func newDropVowelKeys(config json.RawMessage) (Rule, error) {
+	cfg := struct {
+		Preset   string `json:"preset"`
+		Accented bool   `json:"accented"`
+		Y        bool   `json:"y"`
+	}{Preset: "en"}
+	if err := decodeRuleConfig(config, &cfg); err != nil {
+		return nil, err
+	}
+	vowels, ok := vowelPresets[cfg.Preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset '%s'", cfg.Preset)
+	}
+	if cfg.Y && !strings.ContainsRune(vowels, 'y') {
+		vowels += "y"
+	}
+	return dropVowelKeys{vowels: norm.NFC.String(vowels), accented: cfg.Accented}, nil
+}
+
+func (r dropVowelKeys) Key(_ Path, key string) (KeyAction, string) {
+	if r.startsWithVowel(key) {
+		return DropKey, ""
+	}
+	return KeepKey, key
+}
+
+func (r dropVowelKeys) startsWithVowel(key string) bool {
+	vowels := r.vowels
+	if vowels == "" {
+		vowels = vowelPresets["en"]
+	}
+	// the first character, along with any combining marks that go with it
+	first := norm.NFC.String(key[:norm.NFC.NextBoundaryInString(key, true)])
+	if first == "" {
+		// keys can be empty
+		return false
+	}
+	// the whole character is one of the vowels
+	if c, size := utf8.DecodeRuneInString(first); size == len(first) && isVowel(vowels, c) {
+		return true
+	}
+	if !r.accented {
+		return false
+	}
+	// or an accented form of one, i.e. a vowel followed by nothing but combining marks
+	decomposed := norm.NFD.String(first)
+	base, size := utf8.DecodeRuneInString(decomposed)
+	return isVowel(vowels, base) && strings.IndexFunc(decomposed[size:], func(c rune) bool {
+		return !unicode.Is(unicode.Mn, c)
+	}) < 0
+}
+
+func isVowel(vowels string, r rune) bool {
+	return strings.ContainsRune(vowels, unicode.ToLower(r))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDropVowelKeys(t *testing.T) {
+	cases := []struct {
+		testName string
+		config   string
+		key      string
+		want     bool // dropped
+	}{
+		{"empty key", ``, "", false},
+		{"lower case vowel", ``, "apple", true},
+		{"upper case vowel", ``, "Orange", true},
+		{"consonant", ``, "kiwi", false},
+		{"digit", ``, "1apple", false},
+		{"y is not a vowel by default", ``, "yam", false},
+		{"y as a vowel", `{"y": true}`, "Yam", true},
+		{"accented vowel not counted by default", ``, "Élan", false},
+		{"accented vowel", `{"accented": true}`, "Élan", true},
+		{"decomposed accented vowel", `{"accented": true}`, "Élan", true},
+		{"decomposed accented vowel not counted by default", ``, "Élan", false},
+		{"umlaut", `{"accented": true}`, "über", true},
+		{"multiple combining marks", `{"accented": true}`, "ạ́x", true},
+		{"accented consonant", `{"accented": true}`, "ñame", false},
+		{"ligature is not an accented vowel", `{"accented": true}`, "æble", false},
+		{"spanish preset", `{"preset": "es"}`, "Ómnibus", true},
+		{"decomposed in spanish preset", `{"preset": "es"}`, "Ómnibus", true},
+		{"german preset", `{"preset": "de"}`, "über", true},
+		{"german preset, accent not in preset", `{"preset": "de"}`, "élan", false},
+		{"french preset includes y", `{"preset": "fr"}`, "yeux", true},
+		{"nordic preset", `{"preset": "nordic"}`, "Æble", true},
+		{"turkish dotless i", `{"preset": "tr"}`, "ılık", true},
+		{"turkish dotted capital I", `{"preset": "tr", "accented": true}`, "İstanbul", true},
+		{"non-latin script", `{"accented": true}`, "ἀρχή", false},
+		{"emoji", ``, "🍎apple", false},
+		{"invalid utf-8", ``, "\xc3apple", false},
+		{"lone combining mark", `{"accented": true}`, "́apple", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			rule, err := NewRule("drop-vowel-keys", json.RawMessage(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			action, _ := rule.Key(nil, tt.key)
+			if got := action == DropKey; got != tt.want {
+				t.Errorf("%q: expected dropped %t, got %t", tt.key, tt.want, got)
+			}
+		})
+	}
+	if _, err := NewRule("drop-vowel-keys", json.RawMessage(`{"preset": "klingon"}`)); err == nil {
+		t.Errorf("expected error for unknown preset")
+	}
+}
+
+// arbitrary input must never panic: valid JSON is always transformed into valid JSON,
+// and anything else is always an error
+func FuzzTransformJSON(f *testing.F) {
+	for _, seed := range []string{
+		jsonBlob,
+		`{"": 1, "Élan": 2, "über": {"É": [4, {"": ""}]}}`,
+		`[{"": []}, "\xff", -0, 1e400, 2e3, 4.0]`,
+		`{"a": {"b": {"c": [1, [2, [3]]]}}}`,
+		`"just a string"`,
+		`{"\ud83c": "lone surrogate", "ok": "é"}`,
+		`{"apple": 1,}`,
+		`[1 2]`,
+	} {
+		f.Add(seed)
+	}
+	rules := RuleSet{DefaultRules(), dropVowelKeys{accented: true}}
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, preserveFormatting := range []bool{false, true} {
+			var out strings.Builder
+			err := transformJSON(strings.NewReader(input), &out, rules, preserveFormatting)
+			if valid := json.Valid([]byte(input)); valid != (err == nil) {
+				t.Fatalf("valid: %t, but got error: %v (preserving formatting: %t)", valid, err, preserveFormatting)
+			}
+			if err == nil && !json.Valid([]byte(out.String())) {
+				t.Fatalf("invalid output %q (preserving formatting: %t)", out.String(), preserveFormatting)
+			}
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/**
+ * ProcessYAML is ProcessJSON for YAML documents (any number of them, separated by `---`).
+ * The rules see the same tree as they would for JSON: mappings as objects, sequences as
+ * arrays, and numbers as json.Number. Each document is decoded into a yaml.Node tree and
+ * modified in place, so key order, comments, anchors and so on all survive, then written
+ * back out as YAML, one document at a time.
+ */
+func ProcessYAML(filename string, x OpenWriteCloserLoader, spec *TransformSpec) (*ProcessReport, error) {
+	err := processFile(filename, x, func(r io.Reader, w io.Writer) error {
+		return transformYAML(r, w, spec.rules())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write modified YAML data to file: %w", err)
+	}
+	return &ProcessReport{}, nil
+}
+
+func transformYAML(r io.Reader, w io.Writer, rule Rule) error {
+	dec := yaml.NewDecoder(r)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%w yaml data: %w", ErrInvalidData, err)
+		}
+		if err := transformYAMLNode(&doc, nil, rule); err != nil {
+			return err
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+func transformYAMLNode(n *yaml.Node, path Path, rule Rule) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if err := transformYAMLNode(c, path, rule); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		kept := n.Content[:0]
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.ShortTag() == "!!merge" {
+				// `<<: *anchor`, not a key in its own right
+				// (and implicitly so, or the encoder writes it out as `!!merge <<`)
+				k.Tag = ""
+				kept = append(kept, k, v)
+				continue
+			}
+			key := k.Value
+			action, newKey := rule.Key(path, key)
+			if action == DropKey {
+				continue
+			}
+			if action == RenameKey {
+				k.Value, k.Tag = newKey, "!!str"
+			}
+			if err := transformYAMLNode(v, append(path, key), rule); err != nil {
+				return err
+			}
+			kept = append(kept, k, v)
+		}
+		n.Content = kept
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			if err := transformYAMLNode(c, append(path, strconv.Itoa(i)), rule); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		before, err := yamlScalar(n)
+		if err != nil {
+			return fmt.Errorf("at '%s': %w", path, err)
+		}
+		after, err := rule.Value(path, before)
+		if err != nil {
+			return ruleFailed(path, err)
+		}
+		if same, ok := after.(*yaml.Node); (ok && same == n) || sameScalar(before, after) {
+			// untouched, so leave it exactly as it was
+			return nil
+		}
+		return setYAMLScalar(n, after)
+	}
+	// aliases are modified wherever their anchor is
+	return nil
+}
+
+// the value of a scalar node as the rules expect to see it; anything which has no
+// equivalent in JSON (timestamps, binary, custom tags...) is left as the node itself
+func yamlScalar(n *yaml.Node) (any, error) {
+	switch n.ShortTag() {
+	case "!!str":
+		return n.Value, nil
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err := n.Decode(&b)
+		return b, err
+	case "!!int", "!!float":
+		if isJSONNumber(n.Value) {
+			return json.Number(n.Value), nil
+		}
+		// e.g. 0x1F, 1_000, .inf
+		var v any
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case int:
+			return json.Number(strconv.Itoa(v)), nil
+		case int64:
+			return json.Number(strconv.FormatInt(v, 10)), nil
+		case uint64:
+			return json.Number(strconv.FormatUint(v, 10)), nil
+		}
+	}
+	return n, nil
+}
+
+// whether s is written just as a number would be in JSON, e.g. not 0x1F, +1 or 1_000
+func isJSONNumber(s string) bool {
+	return s != "" && (s[0] == '-' || '0' <= s[0] && s[0] <= '9') && json.Valid([]byte(s))
+}
+
+// rewrites a scalar node with the value from a rule
+func setYAMLScalar(n *yaml.Node, v any) error {
+	switch v := v.(type) {
+	case json.Number:
+		n.Value, n.Style = v.String(), 0
+		n.Tag = "!!int"
+		if strings.ContainsAny(n.Value, ".eE") {
+			n.Tag = "!!float"
+		}
+	case string:
+		// the encoder quotes it, if need be, so it doesn't read back as anything but a string
+		n.Value, n.Tag = v, "!!str"
+	case bool:
+		n.Value, n.Tag, n.Style = strconv.FormatBool(v), "!!bool", 0
+	case nil:
+		n.Value, n.Tag, n.Style = "null", "!!null", 0
+	default:
+		// some other value from a rule, let the yaml package deal with it
+		var encoded yaml.Node
+		if err := encoded.Encode(v); err != nil {
+			return err
+		}
+		if encoded.Kind != yaml.ScalarNode {
+			return errors.New("rules can only replace scalars with scalars")
+		}
+		*n = encoded
+	}
+	return nil
+}
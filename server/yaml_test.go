@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	uploadpb "github.com/benjamin-rood/x-grpc/proto"
+	"google.golang.org/grpc"
+)
+
+func TestProcessYAMLAndTOML(t *testing.T) {
+	cases := []struct {
+		testName string
+		filename string
+		data     string
+		process  Processor
+		want     string
+	}{
+		{"yaml", "blob.yaml", yamlBlob, ProcessYAML, expectedYAMLOutput},
+		{"toml", "blob.toml", tomlBlob, ProcessTOML, expectedTOMLOutput},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			buf := NewBufferWriter()
+			buf.m[tt.filename] = []byte(tt.data)
+			if _, err := tt.process(tt.filename, buf, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(buf.m["modified_"+tt.filename]); got != tt.want {
+				t.Errorf("MODIFIED DATA ≠ EXPECTED\n%s", diffLines(got, tt.want))
+			}
+		})
+	}
+}
+
+func TestTransformYAML(t *testing.T) {
+	cases := []struct {
+		testName string
+		spec     string
+		input    string
+		want     string
+		err      string
+	}{
+		{"multiple documents", "", "fig: 2\n---\n- kiwi: 4\n- 0x10\n", "fig: 2000\n---\n- kiwi: 4000\n- 16000\n", ""},
+		{"anchors and aliases", "", "base: &b {fig: 2}\nderived:\n  <<: *b\n  pear: 4\n", "base: &b {fig: 2000}\nderived:\n  <<: *b\n  pear: 4000\n", ""},
+		{"not yaml numbers", "", "fig: 1_000\npear: .inf\nkiwi: '4'\n", "fig: 1000000\npear: .inf\nkiwi: '4'\n", ""},
+		{"rename key", `{"rules": [{"rule": "rename-key", "from": "fig", "to": "true"}]}`, "fig: 2\n", "\"true\": 2\n", ""},
+		{"string stays a string", `{"rules": [{"rule": "uppercase-strings"}]}`, "name: null\nword: kiwi\n", "name: null\nword: KIWI\n", ""},
+		{"out of range", `{"rules": [{"rule": "scale-even-ints", "limit": "int32"}]}`, "fig: 2000000000\n", "", "out of range"},
+		{"not yaml", "", "fig: [2\n", "", "not valid yaml data"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			spec := &TransformSpec{Rules: DefaultRules()}
+			if tt.spec != "" {
+				var err error
+				if spec, err = ParseTransformSpec(tt.spec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var out strings.Builder
+			err := transformYAML(strings.NewReader(tt.input), &out, spec.rules())
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got: %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, out.String())
+			}
+		})
+	}
+}
+
+// the format is picked by mime type, or failing that, by what the upload looks like
+func TestUploaderService_UploadFile_YAMLAndTOML(t *testing.T) {
+	cases := []struct {
+		testName string
+		mimeType string
+		data     string
+		detected string
+		want     string
+	}{
+		{"declared yaml", "application/x-yaml", yamlBlob, "application/yaml", expectedYAMLOutput},
+		{"detected yaml", "", yamlBlob, "application/yaml", expectedYAMLOutput},
+		{"declared toml", "application/toml", tomlBlob, "application/toml", expectedTOMLOutput},
+		{"detected toml", "application/octet-stream", tomlBlob, "application/toml", expectedTOMLOutput},
+	}
+	for _, tt := range cases {
+		t.Run(tt.testName, func(t *testing.T) {
+			buf := NewBufferWriter()
+			uploadSvc := NewCustomUploader(buf, WithMimePolicy(PreferDetected))
+			conn := newTestGRPCServer(t, func(srv *grpc.Server) {
+				uploadpb.RegisterUploaderServer(srv, uploadSvc)
+			})
+			client := uploadpb.NewUploaderClient(conn)
+
+			resp, err := sendDataInChunksToServer(t, client, tt.data, "config", tt.mimeType)
+			if err != nil {
+				t.Fatalf("client.UploadFile: %s", err)
+			}
+			if resp.GetDetectedMimeType() != tt.detected {
+				t.Errorf("expected detected mime type %q, got %q", tt.detected, resp.GetDetectedMimeType())
+			}
+			if got := string(buf.m["modified_config"]); got != tt.want {
+				t.Errorf("MODIFIED DATA ≠ EXPECTED\n%s", diffLines(got, tt.want))
+			}
+		})
+	}
+}